@@ -0,0 +1,165 @@
+package address
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Address is a Dutch address split into its parts. Parts that could not be
+// found are empty.
+type Address struct {
+	Street   string
+	Number   string
+	Addition string // lowercase, e.g. "a", "1" or "hs"
+	Postcode string // 1234AB
+	City     string
+}
+
+var (
+	// Words sites put around the address in a card title
+	noiseRe    = regexp.MustCompile(`(?i)^(?:(?:te huur|nieuw|verhuurd|onder optie|in optie|appartement|woning|studio|penthouse|eengezinswoning|bovenwoning|benedenwoning)\b[\s:!-]*)+`)
+	bracketsRe = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]`)
+	postcodeRe = regexp.MustCompile(`\b([1-9][0-9]{3})\s?([A-Za-z]{2})\b`)
+	// The street can contain digits ("2e Daalsedijk") but doesn't end with one.
+	streetRe = regexp.MustCompile(`(?i)^(.*[^\s\d,-])[\s,]+(\d{1,5})(?:\s*[-/]\s*([a-z0-9]{1,4})|([a-z])|\s+(hs|bis|bg|huis|boven|beneden|[ivx]{1,4}|[a-z]))?(?:\b[\s,]*(.*))?$`)
+)
+
+// Parse splits free text like "Oudegracht 12-A, 3511 AB Utrecht" into an
+// address. Card titles often have more than the address, like "Te huur:" or
+// the project name on another line, which is left out. Text without a house
+// number is kept as the street.
+func Parse(text string) Address {
+	lines := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '|' })
+	if len(lines) > 1 {
+		// The first line with a house number, the next lines can have the postcode and city
+		for i, line := range lines {
+			if parseLine(line).Number != "" {
+				return parseLine(strings.Join(lines[i:], ", "))
+			}
+		}
+	}
+	return parseLine(text)
+}
+
+func parseLine(text string) Address {
+	text = bracketsRe.ReplaceAllString(text, " ")
+	text = strings.Join(strings.Fields(text), " ")
+	text = noiseRe.ReplaceAllString(text, "")
+
+	var a Address
+	var rest string
+	if m := postcodeRe.FindStringSubmatchIndex(text); m != nil {
+		a.Postcode = text[m[2]:m[3]] + strings.ToUpper(text[m[4]:m[5]])
+		rest = text[m[1]:]
+		text = text[:m[0]]
+	}
+
+	m := streetRe.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		a.Street = strings.Trim(text, " ,")
+		return a
+	}
+	a.Street = strings.TrimSpace(m[1])
+	a.Number = strings.TrimLeft(m[2], "0")
+	for _, addition := range m[3:6] {
+		if addition != "" {
+			a.Addition = strings.ToLower(addition)
+		}
+	}
+
+	a.City = cleanCity(rest)
+	if a.City == "" {
+		a.City = cleanCity(m[6])
+	}
+	return a
+}
+
+// cleanCity returns text as a city name if it looks like one.
+func cleanCity(text string) string {
+	text = strings.Trim(text, " ,-")
+	// "Utrecht, Nederland"
+	text, _, _ = strings.Cut(text, ",")
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	for _, r := range text {
+		if !unicode.IsLetter(r) && r != ' ' && r != '-' && r != '\'' && r != '.' {
+			return ""
+		}
+	}
+	return text
+}
+
+// Key identifies the address regardless of formatting, e.g. "Straat 12-A,
+// Utrecht" and "straat 12a, 3511 AB" have the same key. It is
+// street|number|addition, the postcode and city are left out because sites
+// add or drop them in titles; see SamePlace. Text without a house number has
+// the street as key, and text without a street the empty key, which never
+// matches anything.
+func (a Address) Key() string {
+	street := normalize(a.Street)
+	if street == "" {
+		return ""
+	}
+	if a.Number == "" {
+		return street
+	}
+	return street + "|" + a.Number + "|" + a.Addition
+}
+
+// Specific reports whether key is precise enough to identify a home across
+// sources: it has a house number.
+func Specific(key string) bool {
+	return strings.Count(key, "|") == 2
+}
+
+// HasPlace reports whether the address has a postcode or city.
+func (a Address) HasPlace() bool {
+	return a.Postcode != "" || a.City != ""
+}
+
+// SamePlace reports whether a and b can be in the same place without looking
+// up postcodes: one of them has no place, they have the same postcode, or
+// they have no postcodes to compare and the same city.
+func SamePlace(a, b Address) bool {
+	switch {
+	case !a.HasPlace() || !b.HasPlace():
+		return true
+	case a.Postcode != "" && b.Postcode != "":
+		return a.Postcode == b.Postcode
+	}
+	return a.City != "" && normalize(a.City) == normalize(b.City)
+}
+
+// Key returns the key of the address in text.
+func Key(text string) string {
+	return Parse(text).Key()
+}
+
+// SourceKey identifies a listing within one source: the key of its address,
+// or the text itself when it has no key. Text without a key only matches
+// the same text.
+func SourceKey(text string) string {
+	if key := Key(text); key != "" {
+		return key
+	}
+	return "=" + strings.TrimSpace(text)
+}
+
+func normalize(text string) string {
+	text = strings.ToLower(text)
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// NormalizeStreet returns a street or city name in the form used in keys.
+func NormalizeStreet(name string) string {
+	return normalize(name)
+}
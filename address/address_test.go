@@ -0,0 +1,111 @@
+package address
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want Address
+	}{
+		{"Oudegracht 12-A, 3511 AB Utrecht", Address{Street: "Oudegracht", Number: "12", Addition: "a", Postcode: "3511AB", City: "Utrecht"}},
+		{"Te huur: Kerkstraat 1, Amersfoort", Address{Street: "Kerkstraat", Number: "1", City: "Amersfoort"}},
+		{"Project De Wending\nBiltstraat 5 bis\nUtrecht", Address{Street: "Biltstraat", Number: "5", Addition: "bis", City: "Utrecht"}},
+		{"2e Daalsedijk 10", Address{Street: "2e Daalsedijk", Number: "10"}},
+		{"3511 AB Utrecht", Address{Postcode: "3511AB"}},
+		{"Parkzicht", Address{Street: "Parkzicht"}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.text); got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"formatting", "Straat 12-A, Utrecht", "straat 12a, UTRECHT", true},
+		{"postcode and city", "Straat 12-A, 3511 AB", "Straat 12a, Utrecht", true},
+		{"place added", "Oudegracht 12", "Oudegracht 12, 3511 AB Utrecht", true},
+		{"other addition", "Straat 12-A, Utrecht", "Straat 12-B, Utrecht", false},
+		{"other number", "Straat 12, Utrecht", "Straat 14, Utrecht", false},
+		{"only postcodes", "3511 AB Utrecht", "3811 CV Amersfoort", false},
+		{"same postcode without a street", "3511 AB Utrecht", "3511 AB Utrecht", false},
+		{"no text", "", "", false},
+	}
+	for _, tt := range tests {
+		ka, kb := Key(tt.a), Key(tt.b)
+		if same := ka != "" && ka == kb; same != tt.same {
+			t.Errorf("%s: keys %q and %q, same = %v, want %v", tt.name, ka, kb, same, tt.same)
+		}
+	}
+}
+
+func TestSamePlace(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"same city", "Kerkstraat 1, Utrecht", "kerkstraat 1, UTRECHT", true},
+		{"same street in two cities", "Kerkstraat 1, Amersfoort", "Kerkstraat 1, Utrecht", false},
+		{"same postcode", "Oudegracht 12, 3511 AB Utrecht", "Oudegracht 12 3511AB", true},
+		{"same street in two postcodes", "Kerkstraat 1, 3811 CV Amersfoort", "Kerkstraat 1, 3511 AB Utrecht", false},
+		{"postcodes win over cities", "Kerkstraat 1, 3811 CV Utrecht", "Kerkstraat 1, 3511 AB Utrecht", false},
+		{"one without a place", "Kerkstraat 1", "Kerkstraat 1, Utrecht", true},
+		{"both without a place", "Kerkstraat 1", "Kerkstraat 1", true},
+		// Needs the imported locations, see db
+		{"postcode and city", "Kerkstraat 1, 3511 AB", "Kerkstraat 1, Utrecht", false},
+	}
+	for _, tt := range tests {
+		if got := SamePlace(Parse(tt.a), Parse(tt.b)); got != tt.want {
+			t.Errorf("%s: SamePlace(%q, %q) = %v, want %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestKeyWithoutStreet(t *testing.T) {
+	for _, text := range []string{"", "3511 AB Utrecht", "  ,  "} {
+		if key := Key(text); key != "" {
+			t.Errorf("Key(%q) = %q, want empty", text, key)
+		}
+	}
+}
+
+func TestSpecific(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Kerkstraat 1, Utrecht", true},
+		{"Kerkstraat 1, 3511 AB", true},
+		{"Kerkstraat 1", true},
+		{"Parkzicht", false},
+		{"3511 AB Utrecht", false},
+	}
+	for _, tt := range tests {
+		if got := Specific(Key(tt.text)); got != tt.want {
+			t.Errorf("Specific(Key(%q)) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSourceKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Straat 12-A, Utrecht", "straat 12a, utrecht", true},
+		{"3511 AB Utrecht", "3511 AB Utrecht", true},
+		{"3511 AB Utrecht", "3811 CV Amersfoort", false},
+		{"3511 AB Utrecht", " 3511 AB Utrecht ", true},
+	}
+	for _, tt := range tests {
+		if same := SourceKey(tt.a) == SourceKey(tt.b); same != tt.same {
+			t.Errorf("SourceKey(%q) == SourceKey(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
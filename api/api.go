@@ -0,0 +1,455 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"huurwoning/archive"
+	"huurwoning/config"
+	"huurwoning/db"
+	"huurwoning/logger"
+	"huurwoning/photos"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// API is the read-only JSON API over the stored listings and scrape runs.
+// Every request needs one of the tokens in server.api_tokens, sent as
+// "Authorization: Bearer <token>".
+type API struct {
+	store  *config.Store
+	db     *db.Database
+	logger *logger.Logger
+}
+
+func New(store *config.Store, db *db.Database, globalLogger *logger.GlobalLogger) *API {
+	return &API{
+		store:  store,
+		db:     db,
+		logger: globalLogger.Logger("API"),
+	}
+}
+
+// Handler returns the routes of the API, under /api/.
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/listings", a.listListings)
+	mux.HandleFunc("GET /api/listings/{id}", a.getListing)
+	mux.HandleFunc("GET /api/listings/{id}/archive", a.getArchive)
+	mux.HandleFunc("GET /api/export", a.exportListings)
+	mux.HandleFunc("GET /api/photos/{file}", a.getPhoto)
+	mux.HandleFunc("GET /api/sources", a.listSources)
+	mux.HandleFunc("GET /api/runs", a.listRuns)
+	return a.authenticate(mux)
+}
+
+func (a *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens := a.store.Config().Server.APITokens
+		if len(tokens) == 0 {
+			writeError(w, http.StatusForbidden, "the API is disabled, configure server.api_tokens to enable it")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !validToken(token, tokens) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="huurwoning"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validToken(token string, tokens []config.Secret) bool {
+	valid := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Value())) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+type page struct {
+	Items  any `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type listing struct {
+	ID        int64     `json:"id"`
+	Address   string    `json:"address"`
+	Source    string    `json:"source"`
+	URL       string    `json:"url,omitempty"`
+	Price     int       `json:"price,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Active    bool      `json:"active"`
+	// Set when the same home was listed before, possibly by another source
+	CanonicalID int64 `json:"canonical_id,omitempty"`
+
+	// Parts of the address, when found
+	Street      string `json:"street,omitempty"`
+	HouseNumber string `json:"house_number,omitempty"`
+	Addition    string `json:"addition,omitempty"`
+	Postcode    string `json:"postcode,omitempty"`
+	City        string `json:"city,omitempty"`
+
+	// From the imported locations, when the address was found
+	Lat           float64 `json:"lat,omitempty"`
+	Lon           float64 `json:"lon,omitempty"`
+	Neighbourhood string  `json:"neighbourhood,omitempty"`
+
+	Area  int `json:"area,omitempty"`
+	Rooms int `json:"rooms,omitempty"`
+	// YYYY-MM-DD
+	AvailableFrom string `json:"available_from,omitempty"`
+	// Set when the listing was scored, with the explanation
+	Score        *float64 `json:"score,omitempty"`
+	ScoreDetails string   `json:"score_details,omitempty"`
+}
+
+type event struct {
+	Type    string    `json:"type"`
+	Details string    `json:"details,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+type listingWithEvents struct {
+	listing
+	Events []event `json:"events"`
+	// The same home listed by other sources, or earlier by the same one
+	Duplicates []listing `json:"duplicates"`
+	// From the detail page, when it was visited
+	Details *details `json:"details,omitempty"`
+	// Of the archived detail page, /api/listings/{id}/archive
+	Archive string `json:"archive,omitempty"`
+}
+
+type details struct {
+	Description       string    `json:"description,omitempty"`
+	EnergyLabel       string    `json:"energy_label,omitempty"`
+	ServiceCosts      int       `json:"service_costs,omitempty"`
+	Deposit           int       `json:"deposit,omitempty"`
+	IncomeRequirement string    `json:"income_requirement,omitempty"`
+	Furnishing        string    `json:"furnishing,omitempty"`
+	Photos            []photo   `json:"photos"`
+	At                time.Time `json:"at"`
+}
+
+type photo struct {
+	URL string `json:"url"`
+	// Of the downloaded photo, /api/photos/{file}
+	Path string `json:"path,omitempty"`
+}
+
+type run struct {
+	ID         int64      `json:"id"`
+	Source     string     `json:"source"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Found      int        `json:"found"`
+	New        int        `json:"new"`
+}
+
+type source struct {
+	Name           string `json:"name"`
+	Enabled        bool   `json:"enabled"`
+	Interval       string `json:"interval"`
+	ActiveListings int    `json:"active_listings"`
+	LastRun        *run   `json:"last_run,omitempty"`
+	LastSuccess    *run   `json:"last_success,omitempty"`
+}
+
+func toListing(p db.Property) listing {
+	l := listing{
+		ID:        p.ID,
+		Address:   p.Address,
+		Source:    p.Source,
+		URL:       p.URL,
+		Price:     p.Price,
+		FirstSeen: p.FirstSeen,
+		LastSeen:  p.LastSeen,
+		Active:    p.Active,
+
+		CanonicalID: p.CanonicalID,
+		Street:      p.Parts.Street,
+		HouseNumber: p.Parts.Number,
+		Addition:    p.Parts.Addition,
+		Postcode:    p.Parts.Postcode,
+		City:        p.Parts.City,
+
+		Lat:           p.Lat,
+		Lon:           p.Lon,
+		Neighbourhood: p.Neighbourhood,
+
+		Area:         p.Area,
+		Rooms:        p.Rooms,
+		ScoreDetails: p.ScoreDetails,
+	}
+	if !p.AvailableFrom.IsZero() {
+		l.AvailableFrom = p.AvailableFrom.Format(time.DateOnly)
+	}
+	if p.ScoreDetails != "" {
+		l.Score = &p.Score
+	}
+	return l
+}
+
+func toRun(r *db.ScrapeRun) *run {
+	if r == nil {
+		return nil
+	}
+	return &run{
+		ID:         r.ID,
+		Source:     r.Source,
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+		Status:     r.Status,
+		Error:      r.Error,
+		Found:      r.Found,
+		New:        r.New,
+	}
+}
+
+// listListings supports the filters source, active (true/false), since and
+// until (first seen, date or RFC 3339), min_price, max_price and q (address),
+// newest first or with sort=score the best scored first.
+func (a *API) listListings(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	properties, total, err := a.db.ListProperties(r.Context(), filter)
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	items := make([]listing, len(properties))
+	for i, p := range properties {
+		items[i] = toListing(p)
+	}
+	writeJSON(w, http.StatusOK, page{Items: items, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+func (a *API) getListing(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "id must be a number")
+		return
+	}
+
+	property, err := a.db.GetProperty(r.Context(), id)
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "listing not found")
+		return
+	}
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	events, err := a.db.PropertyEvents(r.Context(), id)
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	duplicates, err := a.db.Duplicates(r.Context(), *property)
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	d, err := a.db.GetDetails(r.Context(), id)
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	archived, err := a.db.GetArchive(r.Context(), id)
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	result := listingWithEvents{
+		listing:    toListing(*property),
+		Events:     make([]event, len(events)),
+		Duplicates: make([]listing, len(duplicates)),
+	}
+	if archived != "" {
+		result.Archive = fmt.Sprintf("/api/listings/%d/archive", id)
+	}
+	for i, e := range events {
+		result.Events[i] = event{Type: e.Type, Details: e.Details, At: e.At}
+	}
+	for i, p := range duplicates {
+		result.Duplicates[i] = toListing(p)
+	}
+	if !d.At.IsZero() {
+		result.Details = &details{
+			Description:       d.Description,
+			EnergyLabel:       d.EnergyLabel,
+			ServiceCosts:      d.ServiceCosts,
+			Deposit:           d.Deposit,
+			IncomeRequirement: d.IncomeRequirement,
+			Furnishing:        d.Furnishing,
+			Photos:            make([]photo, len(d.Photos)),
+			At:                d.At,
+		}
+		for i, p := range d.Photos {
+			result.Details.Photos[i] = photo{URL: p.URL}
+			if p.File != "" {
+				result.Details.Photos[i].Path = "/api/photos/" + p.File
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// getArchive returns the archived detail page of a listing, or with
+// format=mhtml the page with its images and styles when that was kept.
+func (a *API) getArchive(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "id must be a number")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = archive.HTML
+	}
+	if format != archive.HTML && format != archive.MHTML {
+		writeError(w, http.StatusBadRequest, "format must be html or mhtml")
+		return
+	}
+
+	name, err := a.db.GetArchive(r.Context(), id)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		a.internalError(w, err)
+		return
+	}
+	f, err := archive.NewStore(a.store.Config().ArchiveDir()).Open(name, format)
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "archive not found")
+		return
+	}
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+	defer f.Close()
+
+	if format == archive.MHTML {
+		w.Header().Set("Content-Type", "multipart/related")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="listing-%d.mhtml"`, id))
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	// The page comes from another site, don't let its scripts run here
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	io.Copy(w, f)
+}
+
+func (a *API) getPhoto(w http.ResponseWriter, r *http.Request) {
+	path, err := photos.NewStore(a.store.Config().PhotoDir()).Path(r.PathValue("file"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "photo not found")
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		writeError(w, http.StatusNotFound, "photo not found")
+		return
+	}
+	// Photos are stored by their content, so they never change
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeFile(w, r, path)
+}
+
+func (a *API) listSources(w http.ResponseWriter, r *http.Request) {
+	cfg := a.store.Config()
+
+	sources := make([]source, 0, len(cfg.Sources))
+	for _, s := range cfg.Sources {
+		active, err := a.db.CountActive(r.Context(), s.Name)
+		if err != nil {
+			a.internalError(w, err)
+			return
+		}
+		last, lastSuccess, err := a.db.LastRuns(r.Context(), s.Name)
+		if err != nil {
+			a.internalError(w, err)
+			return
+		}
+		sources = append(sources, source{
+			Name:           s.Name,
+			Enabled:        !s.Disabled,
+			Interval:       cfg.SourceInterval(s).String(),
+			ActiveListings: active,
+			LastRun:        toRun(last),
+			LastSuccess:    toRun(lastSuccess),
+		})
+	}
+	writeJSON(w, http.StatusOK, sources)
+}
+
+func (a *API) listRuns(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	runs, total, err := a.db.ListRuns(r.Context(), db.RunFilter{
+		Source: strings.ToUpper(r.URL.Query().Get("source")),
+		Status: r.URL.Query().Get("status"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	items := make([]*run, len(runs))
+	for i := range runs {
+		items[i] = toRun(&runs[i])
+	}
+	writeJSON(w, http.StatusOK, page{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+func (a *API) internalError(w http.ResponseWriter, err error) {
+	a.logger.Error("Request failed", "error", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"huurwoning/export"
+)
+
+// exportListings downloads listings or their events as CSV or JSON lines.
+// Parameters: format (csv, jsonl, excel), dataset (listings, events),
+// columns (comma separated), source, since and until.
+func (a *API) exportListings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	opts := export.Options{
+		Format:  q.Get("format"),
+		Dataset: q.Get("dataset"),
+		Source:  q.Get("source"),
+	}
+	if v := q.Get("columns"); v != "" {
+		opts.Columns = strings.Split(v, ",")
+	}
+	var err error
+	if opts.Since, err = parseTime(q.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, "since: "+err.Error())
+		return
+	}
+	if opts.Until, err = parseTime(q.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, "until: "+err.Error())
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Export to memory first, so an error can still be returned as JSON
+	var buf bytes.Buffer
+	if err := export.Write(r.Context(), &buf, a.db, opts); err != nil {
+		a.internalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(opts.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename(opts)))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"huurwoning/db"
+)
+
+const testToken = "0123456789abcdef-token"
+
+// seededAPI returns an API with listings of two sources: Oudegracht 12 had
+// its price changed and Kerkstraat 1 is no longer listed.
+func seededAPI(t *testing.T) *API {
+	t.Helper()
+	a := newTestAPI(t, "server:\n  api_tokens: [\""+testToken+"\"]\n")
+	listings := []struct {
+		source  string
+		listing db.Listing
+	}{
+		{"REBO", db.Listing{Address: "Oudegracht 12, 3511 AB Utrecht", Price: 1500}},
+		{"REBO", db.Listing{Address: "Kerkstraat 1, Amersfoort", Price: 1100}},
+		{"REBO", db.Listing{Address: "Biltstraat 5, Utrecht", Price: 1800}},
+		{"VESTEDA", db.Listing{Address: "Vredenburg 3, Utrecht", Price: 2100}},
+		{"REBO", db.Listing{Address: "Oudegracht 12, 3511 AB Utrecht", Price: 1450}},
+	}
+	for _, l := range listings {
+		if err := a.db.UpsertProperty(l.listing, l.source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.db.MarkInactive("REBO", []string{"Oudegracht 12, 3511 AB Utrecht", "Biltstraat 5, Utrecht"}); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// get requests path from the API and decodes the JSON response into v.
+func get(t *testing.T, a *API, path string, v any) int {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, r)
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return w.Code
+}
+
+type listingsPage struct {
+	Items  []listing `json:"items"`
+	Total  int       `json:"total"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+	Error  string    `json:"error"`
+}
+
+func TestListListings(t *testing.T) {
+	a := seededAPI(t)
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+	yesterday := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+
+	tests := []struct {
+		query     string
+		total     int
+		addresses int
+		limit     int
+		offset    int
+	}{
+		{query: "", total: 4, addresses: 4, limit: defaultLimit},
+		{query: "source=rebo", total: 3, addresses: 3, limit: defaultLimit},
+		{query: "active=false", total: 1, addresses: 1, limit: defaultLimit},
+		{query: "active=true&source=REBO", total: 2, addresses: 2, limit: defaultLimit},
+		{query: "min_price=1500", total: 2, addresses: 2, limit: defaultLimit},
+		{query: "max_price=1500", total: 2, addresses: 2, limit: defaultLimit},
+		{query: "q=oudegracht", total: 1, addresses: 1, limit: defaultLimit},
+		{query: "since=" + tomorrow, total: 0, addresses: 0, limit: defaultLimit},
+		{query: "since=" + yesterday + "&until=" + tomorrow, total: 4, addresses: 4, limit: defaultLimit},
+		{query: "until=" + yesterday, total: 0, addresses: 0, limit: defaultLimit},
+		{query: "since=" + time.Now().Add(-time.Hour).Format(time.RFC3339), total: 4, addresses: 4, limit: defaultLimit},
+		{query: "limit=3", total: 4, addresses: 3, limit: 3},
+		{query: "limit=3&offset=3", total: 4, addresses: 1, limit: 3, offset: 3},
+		{query: "offset=10", total: 4, addresses: 0, limit: defaultLimit, offset: 10},
+		{query: "limit=" + strconv.Itoa(maxLimit), total: 4, addresses: 4, limit: maxLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var p listingsPage
+			if code := get(t, a, "/api/listings?"+tt.query, &p); code != http.StatusOK {
+				t.Fatalf("status %d: %s", code, p.Error)
+			}
+			if p.Total != tt.total || len(p.Items) != tt.addresses || p.Limit != tt.limit || p.Offset != tt.offset {
+				t.Errorf("got total %d, %d items, limit %d, offset %d; want %d, %d, %d, %d",
+					p.Total, len(p.Items), p.Limit, p.Offset, tt.total, tt.addresses, tt.limit, tt.offset)
+			}
+		})
+	}
+}
+
+func TestListListingsBadRequest(t *testing.T) {
+	a := seededAPI(t)
+	for _, query := range []string{
+		"limit=0",
+		"limit=" + strconv.Itoa(maxLimit+1),
+		"limit=ten",
+		"offset=-1",
+		"active=maybe",
+		"since=yesterday",
+		"since=2026-13-01",
+		"until=01-12-2026",
+		"min_price=-5",
+		"max_price=cheap",
+		"sort=price",
+	} {
+		t.Run(query, func(t *testing.T) {
+			var p listingsPage
+			if code := get(t, a, "/api/listings?"+query, &p); code != http.StatusBadRequest || p.Error == "" {
+				t.Errorf("status %d with error %q, want 400 with an error", code, p.Error)
+			}
+		})
+	}
+}
+
+func TestGetListing(t *testing.T) {
+	a := seededAPI(t)
+	var p listingsPage
+	get(t, a, "/api/listings?q=oudegracht", &p)
+	if len(p.Items) != 1 {
+		t.Fatalf("got %d listings", len(p.Items))
+	}
+	id := p.Items[0].ID
+
+	var l listingWithEvents
+	if code := get(t, a, "/api/listings/"+strconv.FormatInt(id, 10), &l); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if l.Price != 1450 || l.Postcode != "3511AB" || l.Street != "Oudegracht" {
+		t.Errorf("listing = %+v", l.listing)
+	}
+	var types []string
+	for _, e := range l.Events {
+		types = append(types, e.Type)
+	}
+	if len(types) != 2 || types[0] != db.EventNew || types[1] != db.EventPriceChanged || l.Events[1].Details != "1500 -> 1450" {
+		t.Errorf("events = %+v, want new and the price change", l.Events)
+	}
+}
+
+func TestGetListingErrors(t *testing.T) {
+	a := seededAPI(t)
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/listings/999", http.StatusNotFound},
+		{"/api/listings/abc", http.StatusBadRequest},
+		{"/api/listings/999/archive", http.StatusNotFound},
+		{"/api/listings/1/archive?format=pdf", http.StatusBadRequest},
+		{"/api/photos/missing.jpg", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var body map[string]string
+			if code := get(t, a, tt.path, &body); code != tt.want || body["error"] == "" {
+				t.Errorf("status %d with %v, want %d with an error", code, body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"huurwoning/db"
+)
+
+func parsePage(r *http.Request) (limit, offset int, err error) {
+	q := r.URL.Query()
+
+	limit = defaultLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a positive number")
+		}
+	}
+	return limit, offset, nil
+}
+
+func parseFilter(r *http.Request) (db.PropertyFilter, error) {
+	q := r.URL.Query()
+
+	var f db.PropertyFilter
+	var err error
+	if f.Limit, f.Offset, err = parsePage(r); err != nil {
+		return f, err
+	}
+
+	f.Source = strings.ToUpper(q.Get("source"))
+	f.Query = q.Get("q")
+
+	switch q.Get("sort") {
+	case "", "newest":
+	case "score":
+		f.Sort = "score"
+	default:
+		return f, fmt.Errorf("sort must be newest or score")
+	}
+
+	if v := q.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("active must be true or false")
+		}
+		f.Active = &active
+	}
+	if f.Since, err = parseTime(q.Get("since")); err != nil {
+		return f, fmt.Errorf("since: %v", err)
+	}
+	if f.Until, err = parseTime(q.Get("until")); err != nil {
+		return f, fmt.Errorf("until: %v", err)
+	}
+	if f.MinPrice, err = parseInt(q.Get("min_price")); err != nil {
+		return f, fmt.Errorf("min_price: %v", err)
+	}
+	if f.MaxPrice, err = parseInt(q.Get("max_price")); err != nil {
+		return f, fmt.Errorf("max_price: %v", err)
+	}
+	return f, nil
+}
+
+// parseTime accepts a date (2006-01-02, local time) or an RFC 3339 timestamp.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (2006-01-02) or RFC 3339 time", v)
+	}
+	// Stored times are local, and compared as text
+	return t.Local(), nil
+}
+
+func parseInt(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a positive number", v)
+	}
+	return n, nil
+}
@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Formats an archive can be kept in
+const (
+	HTML  = "html"
+	MHTML = "mhtml"
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+/[0-9]{8}-[0-9]{6}-[0-9a-f]{8}$`)
+
+// Store keeps gzipped copies of listing pages in a directory per source, as
+// <source>/<time>-<hash of the url>.html.gz and .mhtml.gz. The name of an
+// archive is the path without the extensions, e.g. "REBO/20241019-153012-3fa2b9c1".
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save stores the HTML and, when not empty, the MHTML of the page at url and
+// returns the name of the archive.
+func (s *Store) Save(source, url string, at time.Time, html, mhtml []byte) (string, error) {
+	sum := sha256.Sum256([]byte(url))
+	name := fmt.Sprintf("%s/%s-%s", source, at.Format("20060102-150405"), hex.EncodeToString(sum[:4]))
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid source name %q", source)
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, source), 0755); err != nil {
+		return "", err
+	}
+	if err := writeGzip(s.path(name, HTML), html); err != nil {
+		return "", err
+	}
+	if len(mhtml) > 0 {
+		if err := writeGzip(s.path(name, MHTML), mhtml); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// Open returns the uncompressed archive in the format, fs.ErrNotExist when
+// there is none.
+func (s *Store) Open(name, format string) (io.ReadCloser, error) {
+	if !namePattern.MatchString(name) || (format != HTML && format != MHTML) {
+		return nil, fs.ErrNotExist
+	}
+	f, err := os.Open(s.path(name, format))
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &reader{Reader: r, file: f}, nil
+}
+
+// Prune removes archives older than maxAge and returns the names of the
+// removed archives, also when it fails halfway.
+func (s *Store) Prune(maxAge time.Duration) ([]string, error) {
+	cutoff := time.Now().Add(-maxAge)
+	var removed []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			// The MHTML is never kept without the HTML
+			if rel, err := filepath.Rel(s.dir, path); err == nil && strings.HasSuffix(rel, "."+HTML+".gz") {
+				removed = append(removed, filepath.ToSlash(strings.TrimSuffix(rel, "."+HTML+".gz")))
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (s *Store) path(name, format string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name)+"."+format+".gz")
+}
+
+func writeGzip(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(f)
+	if _, err := w.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// reader closes the file under the gzip reader too.
+type reader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *reader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
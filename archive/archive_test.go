@@ -0,0 +1,46 @@
+package archive
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	s := NewStore(t.TempDir())
+	old, err := s.Save("REBO", "https://example.com/1", time.Now().AddDate(0, 0, -10), []byte("<html>"), []byte("mhtml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent, err := s.Save("REBO", "https://example.com/2", time.Now(), []byte("<html>"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenDaysAgo := time.Now().AddDate(0, 0, -10)
+	for _, format := range []string{HTML, MHTML} {
+		if err := os.Chtimes(s.path(old, format), tenDaysAgo, tenDaysAgo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := s.Prune(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(removed, []string{old}) {
+		t.Errorf("removed %q, want %q", removed, old)
+	}
+	for _, format := range []string{HTML, MHTML} {
+		if _, err := s.Open(old, format); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Open(%s) = %v, want it removed", format, err)
+		}
+	}
+	f, err := s.Open(recent, HTML)
+	if err != nil {
+		t.Fatalf("Open(recent) = %v", err)
+	}
+	f.Close()
+}
@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"huurwoning/config"
 	"huurwoning/logger"
+	"huurwoning/metrics"
 
+	cdpbrowser "github.com/chromedp/cdproto/browser"
 	"github.com/chromedp/chromedp"
 )
 
@@ -18,17 +22,19 @@ type Browser struct {
 	mutex    sync.Mutex
 	logger   *logger.Logger
 	debug    bool
+	options  config.BrowserConfig
 	tabCount int
 	isAlive  bool
 }
 
 const maxRetries = 3
 
-func New(debug bool, globalLogger *logger.GlobalLogger) (*Browser, error) {
+func New(options config.BrowserConfig, debug bool, globalLogger *logger.GlobalLogger) (*Browser, error) {
 	logger := globalLogger.Logger("BROWSER")
 	b := &Browser{
-		logger: logger,
-		debug:  debug,
+		logger:  logger,
+		debug:   debug,
+		options: options,
 	}
 	err := b.createBrowser()
 	if err != nil {
@@ -43,12 +49,28 @@ func (b *Browser) IsAlive() bool {
 	return b.isAlive
 }
 
-func (b *Browser) createBrowser() error {
+// Ping checks that the browser still responds, a hung browser is alive but useless.
+func (b *Browser) Ping(timeout time.Duration) error {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	ctx, alive := b.ctx, b.isAlive
+	b.mutex.Unlock()
+
+	if !alive || ctx == nil {
+		return errors.New("browser not alive")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, _, _, _, _, err := cdpbrowser.GetVersion().Do(ctx)
+		return err
+	}))
+}
 
+// createBrowser launches chromium. The caller must hold the mutex, or be New.
+func (b *Browser) createBrowser() error {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
+		chromedp.Flag("headless", b.options.Headless),
 		chromedp.Flag("no-sandbox", true),
 		chromedp.Flag("disable-setuid-sandbox", true),
 		chromedp.Flag("disable-dev-shm-usage", true),
@@ -58,6 +80,12 @@ func (b *Browser) createBrowser() error {
 		chromedp.Flag("ignore-certificate-errors", true),
 		chromedp.Flag("disable-extensions", true),
 	)
+	if b.options.ExecPath != "" {
+		opts = append(opts, chromedp.ExecPath(b.options.ExecPath))
+	}
+	if b.options.UserAgent != "" {
+		opts = append(opts, chromedp.UserAgent(b.options.UserAgent))
+	}
 
 	allocCtx, _ := chromedp.NewExecAllocator(context.Background(), opts...)
 
@@ -98,6 +126,7 @@ func (b *Browser) RecreateIfNeeded() error {
 	defer b.mutex.Unlock()
 	if !b.isAlive {
 		b.logger.Warn("Browser is not alive, recreating...")
+		metrics.BrowserRestarts.Inc()
 		return b.createBrowser()
 	}
 	return nil
@@ -113,6 +142,7 @@ func (b *Browser) CreateTab() (context.Context, context.CancelFunc, error) {
 
 	tabCtx, cancel := chromedp.NewContext(b.ctx)
 	b.tabCount++
+	metrics.BrowserOpenTabs.Set(float64(b.tabCount))
 	b.logger.Info(fmt.Sprintf("Tab count updated: %d", b.tabCount))
 	return tabCtx, cancel, nil
 }
@@ -122,6 +152,7 @@ func (b *Browser) DecreaseTabCount() {
 	defer b.mutex.Unlock()
 
 	b.tabCount--
+	metrics.BrowserOpenTabs.Set(float64(b.tabCount))
 }
 
 func (b *Browser) RunInTab(ctx context.Context, actions ...chromedp.Action) error {
@@ -0,0 +1,152 @@
+package browser
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"huurwoning/config"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// Accept buttons of common consent managers
+var consentButtons = []string{
+	// Cookiebot
+	"#CybotCookiebotDialogBodyLevelButtonLevelOptinAllowAll",
+	"#CybotCookiebotDialogBodyButtonAccept",
+	"#CybotCookiebotDialogBodyLevelButtonAccept",
+	// OneTrust
+	"#onetrust-accept-btn-handler",
+	// Didomi
+	"#didomi-notice-agree-button",
+	// CookieYes
+	".cky-btn-accept",
+	// Cookie Script
+	"#cookiescript_accept",
+	// consentmanager.net
+	"#cmpbntyestxt",
+	// Osano cookieconsent
+	".cc-allow",
+	".cc-dismiss",
+}
+
+// Texts of accept buttons, only clicked inside an element with cookie or
+// consent in its id or class
+var consentTexts = []string{
+	"alle cookies accepteren", "accepteer alle cookies", "alles accepteren", "cookies accepteren",
+	"accepteren", "accepteer", "akkoord", "ik ga akkoord", "toestaan", "alle cookies toestaan",
+	"accept all cookies", "accept all", "accept cookies", "accept", "allow all", "allow all cookies", "i agree", "agree",
+}
+
+// How long a page is watched for a cookie banner
+const consentWatchMillis = 30000
+
+// acceptJS is a function that clicks the first visible accept button on the
+// page and reports whether it found one. The selectors of the site are
+// tried before the known ones.
+func acceptJS(selectors []string) string {
+	buttons, _ := json.Marshal(slices.Concat(selectors, consentButtons))
+	texts, _ := json.Marshal(consentTexts)
+	return fmt.Sprintf(`
+		() => {
+			const visible = el => el && el.offsetParent !== null && !el.disabled;
+			for (const selector of %s) {
+				let el = null;
+				try { el = document.querySelector(selector); } catch (e) {}
+				if (visible(el)) { el.click(); return true; }
+			}
+			const texts = %s;
+			const candidates = document.querySelectorAll(
+				['cookie', 'consent'].flatMap(word => ['[id*="' + word + '" i]', '[class*="' + word + '" i]'])
+					.flatMap(banner => [banner + ' button', banner + ' a[role="button"]', banner + ' input[type="button"]'])
+					.join(', '));
+			for (const el of candidates) {
+				const text = (el.innerText || el.value || '').trim().toLowerCase();
+				if (texts.includes(text) && visible(el)) { el.click(); return true; }
+			}
+			return false;
+		}
+	`, buttons, texts)
+}
+
+// consentScript runs in every page and frame of a tab. It clicks away a
+// cookie banner when the page loads, or when one shows up within a while.
+func consentScript(selectors []string) string {
+	return fmt.Sprintf(`
+		(() => {
+			const accept = %s;
+			const watch = () => {
+				if (accept()) return;
+				let pending = false;
+				const observer = new MutationObserver(() => {
+					if (pending) return;
+					pending = true;
+					setTimeout(() => {
+						pending = false;
+						if (accept()) observer.disconnect();
+					}, 250);
+				});
+				observer.observe(document.documentElement, {childList: true, subtree: true, attributes: true});
+				setTimeout(() => observer.disconnect(), %d);
+			};
+			if (document.readyState === 'loading') {
+				document.addEventListener('DOMContentLoaded', watch);
+			} else {
+				watch();
+			}
+		})()
+	`, acceptJS(selectors), consentWatchMillis)
+}
+
+// SetupConsent returns an action for a new tab, before it opens a page. It
+// sets the consent cookies, for the host of sourceURL unless they have a
+// domain, and makes every page click away its cookie banner.
+func SetupConsent(cfg config.ConsentConfig, sourceURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if cfg.Disabled {
+			return nil
+		}
+
+		host := ""
+		if u, err := url.Parse(sourceURL); err == nil {
+			host = u.Hostname()
+		}
+		for _, cookie := range cfg.Cookies {
+			err := network.SetCookie(cookie.Name, cookie.Value).
+				WithDomain(cmp.Or(cookie.Domain, host)).
+				WithPath(cmp.Or(cookie.Path, "/")).
+				Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to set cookie %s: %v", cookie.Name, err)
+			}
+		}
+
+		_, err := page.AddScriptToEvaluateOnNewDocument(consentScript(cfg.Selectors)).Do(ctx)
+		return err
+	})
+}
+
+// DismissConsent returns an action that clicks away the cookie banner on the
+// current page, for when it is in the way before the script of SetupConsent
+// got to it. dismissed is set when a button was clicked, if it isn't nil.
+func DismissConsent(cfg config.ConsentConfig, dismissed *bool) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if cfg.Disabled {
+			return nil
+		}
+		var clicked bool
+		if err := chromedp.Evaluate("("+acceptJS(cfg.Selectors)+")()", &clicked).Do(ctx); err != nil {
+			return err
+		}
+		if dismissed != nil {
+			*dismissed = clicked
+		}
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package browser
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ScrollOptions limit ScrollUntilStable. Zero values use the defaults.
+type ScrollOptions struct {
+	// Wait after a scroll for new items to load, 1s by default
+	Wait time.Duration
+	// Scrolls at most, 20 by default
+	MaxScrolls int
+	// Stop once there are this many items, 0 for no limit
+	MaxItems int
+	// Scrolls in a row without new items before all are taken as loaded, 2
+	// by default, as some pages only start loading after the second
+	StableScrolls int
+}
+
+// ScrollUntilStable returns an action that scrolls down a page that loads
+// items on scroll, until the number of elements matching selector stops
+// growing or a limit is reached. The number of items is stored in count
+// when it isn't nil.
+func ScrollUntilStable(selector string, options ScrollOptions, count *int) chromedp.Action {
+	wait := cmp.Or(options.Wait, time.Second)
+	maxScrolls := cmp.Or(options.MaxScrolls, 20)
+	stableScrolls := cmp.Or(options.StableScrolls, 2)
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		items, stable := -1, 0
+		for scrolls := 0; ; scrolls++ {
+			var n int
+			if err := chromedp.Evaluate(countJS(selector), &n).Do(ctx); err != nil {
+				return err
+			}
+			if n == items {
+				stable++
+			} else {
+				stable = 0
+			}
+			items = n
+			if stable == stableScrolls || scrolls == maxScrolls || (options.MaxItems > 0 && n >= options.MaxItems) {
+				break
+			}
+
+			if err := chromedp.Evaluate(scrollJS(selector), nil).Do(ctx); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		if count != nil {
+			*count = items
+		}
+		return nil
+	})
+}
+
+func countJS(selector string) string {
+	return fmt.Sprintf(`document.querySelectorAll(%q).length`, selector)
+}
+
+// scrollJS brings the last item into view, for pages that load when it
+// shows, and scrolls to the bottom, for pages that load near the end.
+func scrollJS(selector string) string {
+	return fmt.Sprintf(`
+		(() => {
+			const items = document.querySelectorAll(%q);
+			if (items.length) items[items.length - 1].scrollIntoView({block: 'end'});
+			window.scrollTo(0, document.scrollingElement.scrollHeight);
+		})()
+	`, selector)
+}
@@ -0,0 +1,640 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"huurwoning/address"
+	"huurwoning/browser"
+	"huurwoning/commute"
+	"huurwoning/config"
+	"huurwoning/db"
+	"huurwoning/export"
+	"huurwoning/geo"
+	"huurwoning/health"
+	"huurwoning/logger"
+	"huurwoning/reporting"
+	"huurwoning/scoring"
+)
+
+type command func(args []string) int
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"run":         runCommand,
+		"scrape":      scrapeCommand,
+		"listings":    listingsCommand,
+		"export":      exportCommand,
+		"notify":      notifyCommand,
+		"sources":     sourcesCommand,
+		"geo":         geoCommand,
+		"commute":     commuteCommand,
+		"score":       scoreCommand,
+		"config":      configCommand,
+		"healthcheck": healthcheckCommand,
+		"help":        helpCommand,
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: main [command] [flags]
+
+commands:
+  run                   scrape all enabled sources on their interval (default)
+  scrape --source NAME  scrape one source, add --once to stop after one run
+                        and --dry-run to skip alerts and database writes
+  listings              list stored listings
+  export                write stored listings or their history as CSV or JSON lines
+  notify test           send a test message through every enabled notifier
+  sources               list the available sources and their config
+  geo import FILE       import a CSV of addresses with coordinates for geocoding
+  geo lookup ADDRESS    show the location found for an address
+  commute import FILE   import an OSM XML extract as the routing graph
+  commute route ADDRESS show the commute times of an address for every profile
+  score                 score the active listings again, e.g. after changing the weights
+  config validate       check a config file
+  healthcheck           exit 1 unless /readyz of the running scraper is OK (needs server.listen)
+
+Run "main <command> -h" for the flags of a command.
+`)
+}
+
+func helpCommand(args []string) int {
+	usage()
+	return 0
+}
+
+// loadConfig loads the config from CONFIG_PATH or config.yaml.
+func loadConfig() (*config.Store, error) {
+	path, required := config.Path()
+	return config.NewStore(path, required, checkSources)
+}
+
+// scrapeCommand implements `scrape --source NAME [--once] [--dry-run]`.
+func scrapeCommand(args []string) int {
+	flags := flag.NewFlagSet("scrape", flag.ExitOnError)
+	name := flags.String("source", "", "source to scrape, e.g. REBO")
+	once := flags.Bool("once", false, "scrape once and exit instead of on the source interval")
+	dryRun := flags.Bool("dry-run", false, "print the results without sending alerts or writing to the database")
+	flags.Parse(args)
+
+	store, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	source := store.Config().Source(normalizeSource(*name))
+	if source == nil {
+		fmt.Fprintf(os.Stderr, "unknown source %q, see `main sources`\n", *name)
+		return 2
+	}
+
+	globalLogger, err := logger.NewGlobalLogger(store.Config().Logging)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create global logger: %v\n", err)
+		return 1
+	}
+	defer globalLogger.Close()
+	globalLogger.SetSecrets(store.Config().SecretValues()...)
+
+	database, err := db.New(store.Config().DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	b, err := browser.New(store.Config().Browser, store.Config().Debug, globalLogger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create browser: %v\n", err)
+		return 1
+	}
+	defer b.Close()
+
+	checker := health.New(store, b, database)
+
+	for {
+		cfg := store.Config()
+		if *dryRun {
+			dry := *cfg
+			dry.DryRun = true
+			cfg = &dry
+		}
+
+		listings, err := runSource(source.Name, b, globalLogger, cfg, database, checker)
+		printListings(listings)
+		if *once {
+			if err != nil {
+				return 1
+			}
+			return 0
+		}
+
+		time.Sleep(cfg.SourceInterval(*cfg.Source(source.Name)))
+	}
+}
+
+func printListings(listings []db.Listing) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tPRICE\tURL")
+	for _, l := range listings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", l.Address, formatPrice(l.Price), l.URL)
+	}
+	w.Flush()
+	fmt.Printf("%d listings\n", len(listings))
+}
+
+// listingsCommand implements `listings`, a query over the stored listings.
+func listingsCommand(args []string) int {
+	flags := flag.NewFlagSet("listings", flag.ExitOnError)
+	filter, parse := propertyFlags(flags)
+	flags.IntVar(&filter.Limit, "limit", 50, "maximum number of listings")
+	flags.IntVar(&filter.Offset, "offset", 0, "number of listings to skip")
+	flags.Parse(args)
+	if err := parse(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	database, code := openDatabase()
+	if database == nil {
+		return code
+	}
+	defer database.Close()
+
+	properties, total, err := database.ListProperties(context.Background(), *filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list properties: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSOURCE\tADDRESS\tPRICE\tFIRST SEEN\tLAST SEEN\tACTIVE")
+	for _, p := range properties {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%t\n", p.ID, p.Source, p.Address, formatPrice(p.Price),
+			p.FirstSeen.Format("2006-01-02 15:04"), p.LastSeen.Format("2006-01-02 15:04"), p.Active)
+	}
+	w.Flush()
+	fmt.Printf("%d of %d listings\n", len(properties), total)
+	return 0
+}
+
+// exportCommand implements `export`, writing stored listings or their history to a file.
+func exportCommand(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	var opts export.Options
+	flags.StringVar(&opts.Format, "format", export.CSV, "csv, jsonl or excel (CSV for Excel)")
+	flags.StringVar(&opts.Dataset, "dataset", export.Listings, "listings, or events for the history of every listing")
+	columns := flags.String("columns", "", "comma separated columns, all when empty")
+	flags.StringVar(&opts.Source, "source", "", "only listings of this source")
+	since := flags.String("since", "", "only listings first seen (events that happened) on or after this date (2006-01-02)")
+	until := flags.String("until", "", "only listings first seen (events that happened) before this date (2006-01-02)")
+	output := flags.String("output", "", "file to write to instead of stdout")
+	flags.Parse(args)
+
+	var err error
+	if *columns != "" {
+		opts.Columns = strings.Split(*columns, ",")
+	}
+	if opts.Since, err = parseDate(*since); err != nil {
+		fmt.Fprintf(os.Stderr, "--since: %v\n", err)
+		return 2
+	}
+	if opts.Until, err = parseDate(*until); err != nil {
+		fmt.Fprintf(os.Stderr, "--until: %v\n", err)
+		return 2
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	database, code := openDatabase()
+	if database == nil {
+		return code
+	}
+	defer database.Close()
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := export.Write(context.Background(), out, database, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// propertyFlags adds the flags that filter listings. The returned func
+// finishes parsing after flags.Parse.
+func propertyFlags(flags *flag.FlagSet) (*db.PropertyFilter, func() error) {
+	filter := &db.PropertyFilter{}
+	source := flags.String("source", "", "only listings of this source")
+	flags.StringVar(&filter.Query, "q", "", "only listings with this text in the address")
+	flags.IntVar(&filter.MinPrice, "min-price", 0, "minimum price")
+	flags.IntVar(&filter.MaxPrice, "max-price", 0, "maximum price")
+	active := flags.Bool("active", false, "only active listings")
+	inactive := flags.Bool("inactive", false, "only inactive listings")
+	since := flags.String("since", "", "only listings first seen on or after this date (2006-01-02)")
+	until := flags.String("until", "", "only listings first seen before this date (2006-01-02)")
+
+	return filter, func() error {
+		filter.Source = normalizeSource(*source)
+		switch {
+		case *active && *inactive:
+			return fmt.Errorf("use either --active or --inactive")
+		case *active, *inactive:
+			filter.Active = active
+		}
+
+		var err error
+		if filter.Since, err = parseDate(*since); err != nil {
+			return fmt.Errorf("--since: %v", err)
+		}
+		if filter.Until, err = parseDate(*until); err != nil {
+			return fmt.Errorf("--until: %v", err)
+		}
+		return nil
+	}
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// openDatabase opens the configured database, or prints why it can't and
+// returns the exit code.
+func openDatabase() (*db.Database, int) {
+	store, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, 1
+	}
+	database, err := db.New(store.Config().DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		return nil, 1
+	}
+	return database, 0
+}
+
+// notifyCommand implements `notify test`.
+func notifyCommand(args []string) int {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprintln(os.Stderr, "usage: main notify test")
+		return 2
+	}
+
+	store, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	results := reporting.New(store.Config().Notifiers).SendTest("Test alert from huurwoning, notifications are working.")
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "No notifiers are enabled")
+		return 1
+	}
+
+	code := 0
+	for _, channel := range slices.Sorted(maps.Keys(results)) {
+		if err := results[channel]; err != nil {
+			fmt.Printf("%s: FAILED: %v\n", channel, err)
+			code = 1
+		} else {
+			fmt.Printf("%s: OK\n", channel)
+		}
+	}
+	return code
+}
+
+// sourcesCommand implements `sources`, listing the available scrapers and how they are configured.
+func sourcesCommand(args []string) int {
+	store, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	cfg := store.Config()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tINTERVAL\tURL")
+	for _, name := range slices.Sorted(maps.Keys(sources)) {
+		s := cfg.Source(name)
+		switch {
+		case s == nil:
+			fmt.Fprintf(w, "%s\tnot configured\t\t\n", name)
+		case s.Disabled:
+			fmt.Fprintf(w, "%s\tdisabled\t%s\t%s\n", name, cfg.SourceInterval(*s), s.URL)
+		default:
+			fmt.Fprintf(w, "%s\tenabled\t%s\t%s\n", name, cfg.SourceInterval(*s), s.URL)
+		}
+	}
+	w.Flush()
+	return 0
+}
+
+// geoCommand implements `geo import [--replace] FILE` and `geo lookup ADDRESS`.
+func geoCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "import" && args[0] != "lookup") {
+		fmt.Fprintln(os.Stderr, "usage: main geo import [--replace] FILE | main geo lookup ADDRESS")
+		return 2
+	}
+
+	if args[0] == "lookup" {
+		database, code := openDatabase()
+		if database == nil {
+			return code
+		}
+		defer database.Close()
+
+		parts := address.Parse(strings.Join(args[1:], " "))
+		fmt.Printf("street: %s\nnumber: %s\naddition: %s\npostcode: %s\ncity: %s\n",
+			parts.Street, parts.Number, parts.Addition, parts.Postcode, parts.City)
+		location, ok, err := database.LookupLocation(parts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to look up location: %v\n", err)
+			return 1
+		}
+		if !ok {
+			fmt.Println("location: not found")
+			return 1
+		}
+		fmt.Printf("location: %f, %f\nneighbourhood: %s\n", location.Lat, location.Lon, location.Neighbourhood)
+		return 0
+	}
+
+	flags := flag.NewFlagSet("geo import", flag.ExitOnError)
+	replace := flags.Bool("replace", false, "remove the previously imported locations first")
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: main geo import [--replace] FILE")
+		return 2
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	database, code := openDatabase()
+	if database == nil {
+		return code
+	}
+	defer database.Close()
+
+	start := time.Now()
+	count, err := geo.Import(f, database, *replace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import locations after %d rows: %v\n", count, err)
+		return 1
+	}
+	fmt.Printf("Imported %d locations in %s\n", count, time.Since(start).Round(time.Second))
+	return 0
+}
+
+// commuteCommand implements `commute import FILE` and `commute route ADDRESS`.
+func commuteCommand(args []string) int {
+	if len(args) < 2 || (args[0] != "import" && args[0] != "route") {
+		fmt.Fprintln(os.Stderr, "usage: main commute import FILE | main commute route ADDRESS")
+		return 2
+	}
+
+	store, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	cfg := store.Config()
+
+	if args[0] == "import" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+
+		start := time.Now()
+		graph, err := commute.ImportOSM(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to import %s: %v\n", args[1], err)
+			return 1
+		}
+		if err := graph.Save(cfg.GraphPath()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save graph: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Imported %d road nodes into %s in %s\n", len(graph.Nodes), cfg.GraphPath(), time.Since(start).Round(time.Second))
+		if cfg.Routing.Router != "graph" {
+			fmt.Println(`Set routing.router to "graph" to use it`)
+		}
+		return 0
+	}
+
+	database, code := openDatabase()
+	if database == nil {
+		return code
+	}
+	defer database.Close()
+
+	text := strings.Join(args[1:], " ")
+	location, err := geo.Locate(database, text)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to look up location: %v\n", err)
+		return 1
+	}
+	if location == nil {
+		fmt.Fprintf(os.Stderr, "No location found for %q, see `main geo lookup`\n", text)
+		return 1
+	}
+
+	router, err := commute.New(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tDESTINATION\tMODE\tMINUTES\tLIMIT")
+	for _, p := range cfg.Profiles {
+		times, err := commute.Times(router, *location, p.Commute)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to estimate commute for %s: %v\n", p.Name, err)
+			return 1
+		}
+		for i, t := range times {
+			limit := "-"
+			if max := p.Commute[i].MaxMinutes; max > 0 {
+				limit = strconv.Itoa(max)
+				if t.TooFar {
+					limit += " (too far)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", p.Name, t.Destination, t.Mode, t.Minutes(), limit)
+		}
+	}
+	w.Flush()
+	return 0
+}
+
+// scoreCommand implements `score [--source NAME]`.
+func scoreCommand(args []string) int {
+	flags := flag.NewFlagSet("score", flag.ExitOnError)
+	source := flags.String("source", "", "only score the listings of this source")
+	verbose := flags.Bool("v", false, "print every score with its explanation")
+	flags.Parse(args)
+
+	store, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	evaluator, err := scoring.NewEvaluator(store.Config())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Estimating commute times in a straight line: %v\n", err)
+	}
+
+	database, code := openDatabase()
+	if database == nil {
+		return code
+	}
+	defer database.Close()
+
+	active := true
+	properties, _, err := database.ListProperties(context.Background(), db.PropertyFilter{
+		Source: normalizeSource(*source),
+		Active: &active,
+		Limit:  -1,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list properties: %v\n", err)
+		return 1
+	}
+
+	scored := 0
+	for _, p := range properties {
+		evaluation, _, err := evaluator.Evaluate(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p.Address, err)
+		}
+		if !evaluation.Scored() {
+			continue
+		}
+		if err := database.SetScore(p.Source, p.Address, evaluation.Score, evaluation.String()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to store score: %v\n", err)
+			return 1
+		}
+		scored++
+		if *verbose {
+			fmt.Printf("%s %s: %s\n", p.Source, p.Address, evaluation)
+		}
+	}
+	fmt.Printf("Scored %d of %d active listings\n", scored, len(properties))
+	return 0
+}
+
+// configCommand implements `config validate [path]`.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: main config validate [path]")
+		return 2
+	}
+
+	path, required := config.Path()
+	if len(args) > 1 {
+		path, required = args[1], true
+	}
+
+	store, err := config.NewStore(path, required, checkSources)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	c := store.Config()
+	fmt.Printf("%s: OK (%d sources, %d enabled)\n", path, len(c.Sources), len(c.EnabledSources()))
+	return 0
+}
+
+// healthcheckCommand implements `healthcheck`: ask /readyz of the server on
+// server.listen, for the Docker HEALTHCHECK. It fails when the server is
+// turned off, as the health can't be checked then.
+func healthcheckCommand(args []string) int {
+	flags := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 5*time.Second, "how long to wait for an answer")
+	flags.Parse(args)
+
+	store, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	listen := store.Config().Server.Listen
+	if listen == "" {
+		// Without the server there is no way to tell a wedged scraper from a working one
+		fmt.Fprintln(os.Stderr, "server.listen is empty, set it to check the health of the scraper")
+		return 1
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// Listening on every address includes loopback
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	client := http.Client{Timeout: *timeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/readyz")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	return 0
+}
+
+func normalizeSource(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+func formatPrice(price int) string {
+	if price <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("€%d", price)
+}
@@ -0,0 +1,103 @@
+package commute
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"huurwoning/config"
+	"huurwoning/geo"
+)
+
+// Router estimates the travel time between two points. Implementations can
+// be swapped, e.g. for a local stand-in of a routing service.
+type Router interface {
+	Duration(from, to geo.Point, mode string) (time.Duration, error)
+}
+
+// New returns the router configured in routing. The graph router falls back
+// to the straight-line estimate for modes the graph doesn't know, like transit.
+func New(cfg *config.Config) (Router, error) {
+	straight := StraightLine{Factor: cfg.Routing.Factor, Speeds: cfg.Routing.Speeds}
+
+	switch cfg.Routing.Router {
+	case "graph":
+		graph, err := LoadGraph(cfg.GraphPath())
+		if err != nil {
+			return nil, err
+		}
+		return &GraphRouter{Graph: graph, Speeds: cfg.Routing.Speeds, Fallback: straight}, nil
+	case "osrm":
+		return &OSRM{URL: cfg.Routing.URL, Fallback: straight}, nil
+	default:
+		return straight, nil
+	}
+}
+
+// StraightLine estimates a route as the straight-line distance times a detour factor.
+type StraightLine struct {
+	Factor float64
+	// km/h per mode
+	Speeds map[string]float64
+}
+
+func (s StraightLine) Duration(from, to geo.Point, mode string) (time.Duration, error) {
+	speed, ok := s.Speeds[mode]
+	if !ok || speed <= 0 {
+		return 0, fmt.Errorf("no speed for mode %q", mode)
+	}
+	km := geo.Distance(from, to) * s.Factor
+	return time.Duration(km / speed * float64(time.Hour)), nil
+}
+
+// Time is the travel time from a listing to one destination.
+type Time struct {
+	Destination string
+	Mode        string
+	Duration    time.Duration
+	// Longer than the destination's max_minutes
+	TooFar bool
+}
+
+func (t Time) String() string {
+	return fmt.Sprintf("%s %d min by %s", t.Destination, t.Minutes(), t.Mode)
+}
+
+// Minutes rounds the travel time up, a commute of 61 seconds isn't 1 minute.
+func (t Time) Minutes() int {
+	return int((t.Duration + time.Minute - 1) / time.Minute)
+}
+
+// Times returns the travel time from location to every destination.
+func Times(r Router, location geo.Point, destinations []config.CommuteConfig) ([]Time, error) {
+	times := make([]Time, 0, len(destinations))
+	for _, d := range destinations {
+		duration, err := r.Duration(location, geo.Point{Lat: d.Lat, Lon: d.Lon}, d.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("route to %s: %w", d.Name, err)
+		}
+		times = append(times, Time{
+			Destination: d.Name,
+			Mode:        d.Mode,
+			Duration:    duration,
+			TooFar:      d.MaxMinutes > 0 && duration > time.Duration(d.MaxMinutes)*time.Minute,
+		})
+	}
+	return times, nil
+}
+
+// Within reports whether none of the times exceed their limit.
+func Within(times []Time) bool {
+	return !slices.ContainsFunc(times, func(t Time) bool { return t.TooFar })
+}
+
+// Shortest returns the shortest travel time, or 0 without times.
+func Shortest(times []Time) time.Duration {
+	var shortest time.Duration
+	for i, t := range times {
+		if i == 0 || t.Duration < shortest {
+			shortest = t.Duration
+		}
+	}
+	return shortest
+}
@@ -0,0 +1,232 @@
+package commute
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"huurwoning/config"
+	"huurwoning/geo"
+)
+
+// fixed is a stand-in router that always returns the same duration.
+type fixed time.Duration
+
+func (f fixed) Duration(from, to geo.Point, mode string) (time.Duration, error) {
+	return time.Duration(f), nil
+}
+
+func TestStraightLine(t *testing.T) {
+	s := StraightLine{Factor: 1.5, Speeds: map[string]float64{"bike": 15, "car": 0}}
+	from, to := geo.Point{Lat: 52.0, Lon: 5.0}, geo.Point{Lat: 52.1, Lon: 5.0}
+
+	got, err := s.Duration(from, to, "bike")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Duration(geo.Distance(from, to) * 1.5 / 15 * float64(time.Hour))
+	if got != want {
+		t.Errorf("Duration() = %v, want %v", got, want)
+	}
+
+	for _, mode := range []string{"car", "boat"} {
+		if _, err := s.Duration(from, to, mode); err == nil {
+			t.Errorf("Duration(%q) without a speed didn't fail", mode)
+		}
+	}
+}
+
+func TestOSRM(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		mode    string
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "route", status: http.StatusOK, body: `{"code":"Ok","routes":[{"duration":612.5}]}`, mode: "bike", want: 612500 * time.Millisecond},
+		{name: "no route", status: http.StatusOK, body: `{"code":"NoRoute","message":"Impossible route"}`, mode: "bike", wantErr: "NoRoute"},
+		{name: "error status", status: http.StatusBadRequest, body: `{"code":"InvalidQuery","message":"Query string malformed"}`, mode: "bike", wantErr: "400"},
+		{name: "server error", status: http.StatusInternalServerError, body: `<html>oops</html>`, mode: "bike", wantErr: "500"},
+		{name: "bad JSON", status: http.StatusOK, body: `{"code":`, mode: "bike", wantErr: "routing service returned 200"},
+		{name: "transit uses the fallback", mode: "transit", want: 42 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			o := &OSRM{URL: server.URL + "/", Client: server.Client(), Fallback: fixed(42 * time.Minute)}
+			got, err := o.Duration(geo.Point{Lat: 52.09, Lon: 5.11}, geo.Point{Lat: 52.1, Lon: 5.12}, tt.mode)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Duration() error = %v, want one with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+			if tt.mode != "transit" && path != "/route/v1/bike/5.110000,52.090000;5.120000,52.100000" {
+				t.Errorf("requested %s", path)
+			}
+		})
+	}
+}
+
+func TestOSRMUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	o := &OSRM{URL: server.URL}
+	if _, err := o.Duration(geo.Point{}, geo.Point{}, "bike"); err == nil {
+		t.Error("Duration() of a closed server didn't fail")
+	}
+}
+
+// testGraph is a square of roads with a long diagonal, and a car-only road:
+//
+//	3 ---- 2      4 (car only)
+//	|    / |      |
+//	|  /   |      5
+//	0 ---- 1
+func testGraph() *Graph {
+	g := &Graph{
+		Nodes: []geo.Point{
+			{Lat: 52.000, Lon: 5.000},
+			{Lat: 52.000, Lon: 5.010},
+			{Lat: 52.010, Lon: 5.010},
+			{Lat: 52.010, Lon: 5.000},
+			{Lat: 52.010, Lon: 5.030},
+			{Lat: 52.000, Lon: 5.030},
+		},
+		Edges: make([][]Edge, 6),
+	}
+	road := func(a, b int32, meters float32, modes uint8) {
+		g.Edges[a] = append(g.Edges[a], Edge{To: b, Meters: meters, Modes: modes})
+		g.Edges[b] = append(g.Edges[b], Edge{To: a, Meters: meters, Modes: modes})
+	}
+	road(0, 1, 700, bike|walk|car)
+	road(1, 2, 1100, bike|walk|car)
+	road(2, 3, 700, bike|walk|car)
+	road(3, 0, 1100, bike|walk)
+	road(0, 2, 5000, bike)
+	road(4, 5, 1100, car)
+	return g
+}
+
+func TestShortest(t *testing.T) {
+	g := testGraph()
+	tests := []struct {
+		name       string
+		start, end int
+		mode       uint8
+		want       float64
+		found      bool
+	}{
+		{name: "around the square", start: 0, end: 2, mode: bike, want: 1800, found: true},
+		{name: "same node", start: 1, end: 1, mode: bike, want: 0, found: true},
+		{name: "car can't take 3-0", start: 3, end: 0, mode: car, want: 2500, found: true},
+		{name: "not connected", start: 0, end: 5, mode: car, found: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := g.shortest(tt.start, tt.end, tt.mode)
+			if found != tt.found || got != tt.want {
+				t.Errorf("shortest() = %v, %v, want %v, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestNearest(t *testing.T) {
+	g := testGraph()
+	tests := []struct {
+		name string
+		p    geo.Point
+		mode uint8
+		want int
+	}{
+		{name: "next to a node", p: geo.Point{Lat: 52.0005, Lon: 5.0095}, mode: bike, want: 1},
+		{name: "across a cell border", p: geo.Point{Lat: 52.0099, Lon: 5.0101}, mode: bike, want: 2},
+		{name: "closest usable node", p: geo.Point{Lat: 52.0005, Lon: 5.024}, mode: bike, want: 1},
+		{name: "car road", p: geo.Point{Lat: 52.0005, Lon: 5.024}, mode: car, want: 5},
+		{name: "too far", p: geo.Point{Lat: 52.1, Lon: 5.0}, mode: bike, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, km := g.nearest(tt.p, tt.mode)
+			if got != tt.want {
+				t.Errorf("nearest() = %d (%.2f km), want %d", got, km, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphRouter(t *testing.T) {
+	r := &GraphRouter{Graph: testGraph(), Speeds: map[string]float64{"bike": 18, "transit": 20}, Fallback: fixed(42 * time.Minute)}
+	tests := []struct {
+		name     string
+		from, to geo.Point
+		mode     string
+		want     time.Duration
+	}{
+		{name: "route", from: geo.Point{Lat: 52.000, Lon: 5.000}, to: geo.Point{Lat: 52.010, Lon: 5.010}, mode: "bike", want: 6 * time.Minute},
+		{name: "mode without roads", from: geo.Point{Lat: 52.000, Lon: 5.000}, to: geo.Point{Lat: 52.010, Lon: 5.010}, mode: "transit", want: 42 * time.Minute},
+		{name: "outside the graph", from: geo.Point{Lat: 52.000, Lon: 5.000}, to: geo.Point{Lat: 53, Lon: 6}, mode: "bike", want: 42 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Duration(tt.from, tt.to, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Round(time.Second) != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var errNoRoute = errors.New("no route")
+
+type failing struct{}
+
+func (failing) Duration(from, to geo.Point, mode string) (time.Duration, error) {
+	return 0, errNoRoute
+}
+
+func TestTimes(t *testing.T) {
+	destinations := []config.CommuteConfig{
+		{Name: "work", Mode: "bike", MaxMinutes: 30},
+		{Name: "gym", Mode: "walk", MaxMinutes: 15},
+		{Name: "parents", Mode: "car"},
+	}
+	times, err := Times(fixed(20*time.Minute), geo.Point{}, destinations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tooFar := make([]bool, len(times))
+	for i, tm := range times {
+		tooFar[i] = tm.TooFar
+	}
+	if !slices.Equal(tooFar, []bool{false, true, false}) || Within(times) {
+		t.Errorf("too far = %v, want only the gym", tooFar)
+	}
+
+	if _, err := Times(failing{}, geo.Point{}, destinations); !errors.Is(err, errNoRoute) {
+		t.Errorf("Times() error = %v, want %v", err, errNoRoute)
+	}
+}
@@ -0,0 +1,240 @@
+package commute
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"huurwoning/geo"
+)
+
+// Modes a road can be used with
+const (
+	bike uint8 = 1 << iota
+	walk
+	car
+)
+
+func modeBit(mode string) uint8 {
+	switch mode {
+	case "bike":
+		return bike
+	case "walk":
+		return walk
+	case "car":
+		return car
+	}
+	return 0
+}
+
+// A listing or destination further than this from the nearest road is
+// outside the imported extract.
+const maxSnapKM = 1.0
+
+// Graph is a road network imported from an OSM extract.
+type Graph struct {
+	Nodes []geo.Point
+	// Edges per node
+	Edges [][]Edge
+
+	// Nodes per grid cell, built on the first lookup
+	index     map[cell][]int32
+	indexOnce sync.Once
+}
+
+// Cells of the index are about 1.1 by 0.7 km in the Netherlands
+const cellDegrees = 0.01
+
+type cell struct {
+	lat, lon int32
+}
+
+func cellOf(p geo.Point) cell {
+	return cell{int32(math.Floor(p.Lat / cellDegrees)), int32(math.Floor(p.Lon / cellDegrees))}
+}
+
+type Edge struct {
+	To     int32
+	Meters float32
+	Modes  uint8
+}
+
+// GraphRouter finds the shortest route over a Graph.
+type GraphRouter struct {
+	Graph *Graph
+	// km/h per mode
+	Speeds map[string]float64
+	// Used for modes the graph doesn't have and points outside of it
+	Fallback Router
+}
+
+func (g *GraphRouter) Duration(from, to geo.Point, mode string) (time.Duration, error) {
+	bit := modeBit(mode)
+	speed := g.Speeds[mode]
+	if bit == 0 || speed <= 0 {
+		return g.Fallback.Duration(from, to, mode)
+	}
+
+	start, startKM := g.Graph.nearest(from, bit)
+	end, endKM := g.Graph.nearest(to, bit)
+	if start < 0 || end < 0 || startKM > maxSnapKM || endKM > maxSnapKM {
+		return g.Fallback.Duration(from, to, mode)
+	}
+
+	meters, ok := g.Graph.shortest(start, end, bit)
+	if !ok {
+		return g.Fallback.Duration(from, to, mode)
+	}
+	km := startKM + meters/1000 + endKM
+	return time.Duration(km / speed * float64(time.Hour)), nil
+}
+
+// nearest returns the node closest to p that has a road usable with mode,
+// or -1 when there is none within maxSnapKM. Only the cells of the index
+// around p are searched.
+func (g *Graph) nearest(p geo.Point, mode uint8) (int, float64) {
+	g.indexOnce.Do(g.buildIndex)
+
+	// Degrees of longitude get shorter towards the poles
+	kmPerDegree := 111.32
+	latCells := int32(math.Ceil(maxSnapKM / kmPerDegree / cellDegrees))
+	lonCells := int32(math.Ceil(maxSnapKM / (kmPerDegree * max(math.Cos(p.Lat*math.Pi/180), 0.01)) / cellDegrees))
+
+	best, bestKM := -1, math.Inf(1)
+	center := cellOf(p)
+	for lat := center.lat - latCells; lat <= center.lat+latCells; lat++ {
+		for lon := center.lon - lonCells; lon <= center.lon+lonCells; lon++ {
+			for _, i := range g.index[cell{lat, lon}] {
+				if !g.usable(int(i), mode) {
+					continue
+				}
+				if km := geo.Distance(p, g.Nodes[i]); km < bestKM && km <= maxSnapKM {
+					best, bestKM = int(i), km
+				}
+			}
+		}
+	}
+	return best, bestKM
+}
+
+func (g *Graph) buildIndex() {
+	g.index = make(map[cell][]int32)
+	for i, n := range g.Nodes {
+		c := cellOf(n)
+		g.index[c] = append(g.index[c], int32(i))
+	}
+}
+
+func (g *Graph) usable(node int, mode uint8) bool {
+	for _, e := range g.Edges[node] {
+		if e.Modes&mode != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// shortest runs Dijkstra from start until end is reached, returning the distance in metres.
+func (g *Graph) shortest(start, end int, mode uint8) (float64, bool) {
+	dist := map[int]float64{start: 0}
+	queue := &nodeQueue{{node: start}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(queued)
+		if current.node == end {
+			return current.dist, true
+		}
+		if current.dist > dist[current.node] {
+			continue
+		}
+		for _, e := range g.Edges[current.node] {
+			if e.Modes&mode == 0 {
+				continue
+			}
+			next, d := int(e.To), current.dist+float64(e.Meters)
+			if known, ok := dist[next]; !ok || d < known {
+				dist[next] = d
+				heap.Push(queue, queued{node: next, dist: d})
+			}
+		}
+	}
+	return 0, false
+}
+
+type queued struct {
+	node int
+	dist float64
+}
+
+type nodeQueue []queued
+
+func (q nodeQueue) Len() int           { return len(q) }
+func (q nodeQueue) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q nodeQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x any)        { *q = append(*q, x.(queued)) }
+func (q *nodeQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Save writes the graph to path, replacing it atomically.
+func (g *Graph) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".commute-*.graph")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(g); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+var graphs struct {
+	sync.Mutex
+	path     string
+	modified time.Time
+	graph    *Graph
+}
+
+// LoadGraph reads the graph at path. The last graph is kept in memory until
+// the file changes, so routers can be created for every run.
+func LoadGraph(path string) (*Graph, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("no routing graph, import one with `main commute import`: %w", err)
+	}
+
+	graphs.Lock()
+	defer graphs.Unlock()
+	if graphs.graph != nil && graphs.path == path && graphs.modified.Equal(info.ModTime()) {
+		return graphs.graph, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var g Graph
+	if err := gob.NewDecoder(f).Decode(&g); err != nil {
+		return nil, fmt.Errorf("failed to read graph %s: %w", path, err)
+	}
+	graphs.path, graphs.modified, graphs.graph = path, info.ModTime(), &g
+	return &g, nil
+}
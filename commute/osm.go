@@ -0,0 +1,161 @@
+package commute
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"huurwoning/geo"
+)
+
+// Modes allowed per highway type, roads not listed here are skipped.
+var highways = map[string]uint8{
+	"motorway":       car,
+	"motorway_link":  car,
+	"trunk":          car,
+	"trunk_link":     car,
+	"primary":        bike | walk | car,
+	"primary_link":   bike | walk | car,
+	"secondary":      bike | walk | car,
+	"secondary_link": bike | walk | car,
+	"tertiary":       bike | walk | car,
+	"tertiary_link":  bike | walk | car,
+	"unclassified":   bike | walk | car,
+	"residential":    bike | walk | car,
+	"living_street":  bike | walk | car,
+	"service":        bike | walk | car,
+	"road":           bike | walk | car,
+	"track":          bike | walk,
+	"cycleway":       bike | walk,
+	"path":           bike | walk,
+	"footway":        walk,
+	"pedestrian":     walk,
+	"steps":          walk,
+}
+
+type osmNode struct {
+	ID  int64   `xml:"id,attr"`
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type osmWay struct {
+	Nodes []struct {
+		Ref int64 `xml:"ref,attr"`
+	} `xml:"nd"`
+	Tags []struct {
+		Key   string `xml:"k,attr"`
+		Value string `xml:"v,attr"`
+	} `xml:"tag"`
+}
+
+// ImportOSM builds a road graph from an OSM XML extract, e.g. a city exported
+// from openstreetmap.org or converted from a .pbf with osmium.
+func ImportOSM(r io.Reader) (*Graph, error) {
+	dec := xml.NewDecoder(r)
+	points := make(map[int64]geo.Point)
+	index := make(map[int64]int32)
+	g := &Graph{}
+
+	node := func(id int64) (int32, bool) {
+		if i, ok := index[id]; ok {
+			return i, true
+		}
+		p, ok := points[id]
+		if !ok {
+			return 0, false
+		}
+		i := int32(len(g.Nodes))
+		index[id] = i
+		g.Nodes = append(g.Nodes, p)
+		g.Edges = append(g.Edges, nil)
+		return i, true
+	}
+
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid OSM XML: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "node":
+			var n osmNode
+			if err := dec.DecodeElement(&n, &start); err != nil {
+				return nil, fmt.Errorf("invalid node: %w", err)
+			}
+			points[n.ID] = geo.Point{Lat: n.Lat, Lon: n.Lon}
+
+		case "way":
+			var w osmWay
+			if err := dec.DecodeElement(&w, &start); err != nil {
+				return nil, fmt.Errorf("invalid way: %w", err)
+			}
+			modes, oneway := wayModes(w)
+			if modes == 0 {
+				continue
+			}
+			for i := 1; i < len(w.Nodes); i++ {
+				a, okA := node(w.Nodes[i-1].Ref)
+				b, okB := node(w.Nodes[i].Ref)
+				if !okA || !okB {
+					continue
+				}
+				meters := float32(geo.Distance(g.Nodes[a], g.Nodes[b]) * 1000)
+				g.Edges[a] = append(g.Edges[a], Edge{To: b, Meters: meters, Modes: modes})
+				back := modes
+				if oneway {
+					back &^= car
+				}
+				if back != 0 {
+					g.Edges[b] = append(g.Edges[b], Edge{To: a, Meters: meters, Modes: back})
+				}
+			}
+		}
+	}
+
+	if len(g.Nodes) == 0 {
+		return nil, fmt.Errorf("no roads found, nodes have to come before the ways like in every OSM export")
+	}
+	return g, nil
+}
+
+// wayModes returns the modes a way can be used with, and whether cars can
+// only use it in one direction.
+func wayModes(w osmWay) (uint8, bool) {
+	tags := make(map[string]string, len(w.Tags))
+	for _, t := range w.Tags {
+		tags[t.Key] = t.Value
+	}
+
+	modes, ok := highways[tags["highway"]]
+	if !ok || tags["area"] == "yes" {
+		return 0, false
+	}
+	if tags["access"] == "no" || tags["access"] == "private" {
+		return 0, false
+	}
+	switch tags["bicycle"] {
+	case "no", "dismount":
+		modes &^= bike
+	case "yes", "designated":
+		modes |= bike
+	}
+	switch tags["foot"] {
+	case "no":
+		modes &^= walk
+	case "yes", "designated":
+		modes |= walk
+	}
+	if tags["motor_vehicle"] == "no" || tags["motorcar"] == "no" {
+		modes &^= car
+	}
+	return modes, tags["oneway"] == "yes" || tags["junction"] == "roundabout"
+}
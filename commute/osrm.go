@@ -0,0 +1,60 @@
+package commute
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"huurwoning/geo"
+)
+
+// OSRM asks an OSRM compatible routing service, with a profile per mode like
+// /route/v1/bike/. Modes it can't route, like transit, use the fallback.
+type OSRM struct {
+	URL      string
+	Client   *http.Client
+	Fallback Router
+}
+
+type osrmResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Routes  []struct {
+		Duration float64 `json:"duration"`
+	} `json:"routes"`
+}
+
+func (o *OSRM) Duration(from, to geo.Point, mode string) (time.Duration, error) {
+	if mode == "transit" && o.Fallback != nil {
+		return o.Fallback.Duration(from, to, mode)
+	}
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	u := fmt.Sprintf("%s/route/v1/%s/%f,%f;%f,%f?overview=false",
+		strings.TrimSuffix(o.URL, "/"), url.PathEscape(mode), from.Lon, from.Lat, to.Lon, to.Lat)
+	resp, err := client.Get(u)
+	if err != nil {
+		return 0, fmt.Errorf("routing service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Errors like NoRoute come with a code and message in the body
+	var body osrmResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("routing service returned %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("routing service returned %s: %s %s", resp.Status, body.Code, body.Message)
+	}
+	if body.Code != "Ok" || len(body.Routes) == 0 {
+		return 0, fmt.Errorf("routing service: %s %s", body.Code, body.Message)
+	}
+	return time.Duration(body.Routes[0].Duration * float64(time.Second)), nil
+}
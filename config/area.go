@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"huurwoning/geo"
+)
+
+// AreaConfig limits a profile to places: a listing has to be within one of
+// the circles or inside one of the GeoJSON polygons. Listings that couldn't
+// be geocoded pass, unless exclude_unknown is set.
+type AreaConfig struct {
+	Within []CircleConfig `yaml:"within"`
+	// GeoJSON files with Polygon or MultiPolygon features, relative to the config file
+	GeoJSON        []string `yaml:"geojson"`
+	ExcludeUnknown bool     `yaml:"exclude_unknown"`
+
+	polygons []geo.Polygon
+}
+
+// CircleConfig is a radius around a point, e.g. 5 km around Utrecht Centraal.
+type CircleConfig struct {
+	Name     string  `yaml:"name"`
+	Lat      float64 `yaml:"lat"`
+	Lon      float64 `yaml:"lon"`
+	RadiusKM float64 `yaml:"radius_km"`
+}
+
+// Empty reports whether the area doesn't limit anything.
+func (a AreaConfig) Empty() bool {
+	return len(a.Within) == 0 && len(a.GeoJSON) == 0
+}
+
+// Contains reports whether location is in the area, nil meaning the location is unknown.
+func (a AreaConfig) Contains(location *geo.Point) bool {
+	if a.Empty() {
+		return true
+	}
+	if location == nil {
+		return !a.ExcludeUnknown
+	}
+	for _, c := range a.Within {
+		if geo.Distance(geo.Point{Lat: c.Lat, Lon: c.Lon}, *location) <= c.RadiusKM {
+			return true
+		}
+	}
+	for _, polygon := range a.polygons {
+		if polygon.Contains(*location) {
+			return true
+		}
+	}
+	return false
+}
+
+// area validates the circles and loads the GeoJSON files of a.
+func (v *validator) area(field string, a *AreaConfig, dir string) {
+	for i, c := range a.Within {
+		circle := fmt.Sprintf("%s.within[%d]", field, i)
+		if !validPoint(c.Lat, c.Lon) {
+			v.addf(circle, "needs a valid lat and lon")
+		}
+		if c.RadiusKM <= 0 {
+			v.addf(circle+".radius_km", "must be positive")
+		}
+	}
+
+	a.polygons = nil
+	for i, path := range a.GeoJSON {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			v.addf(fmt.Sprintf("%s.geojson[%d]", field, i), "%v", err)
+			continue
+		}
+		polygons, err := geo.ParseGeoJSON(data)
+		if err != nil {
+			v.addf(fmt.Sprintf("%s.geojson[%d]", field, i), "%s: %v", path, err)
+			continue
+		}
+		a.polygons = append(a.polygons, polygons...)
+	}
+}
@@ -1,69 +1,582 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
+	"huurwoning/geo"
+	"huurwoning/rules"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is used when CONFIG_PATH is not set.
+const DefaultPath = "config.yaml"
+
+// The default BouwInvest URL searches 10 km around Utrecht. When profile
+// areas decide where, it searches the whole country instead.
+const (
+	bouwInvestURL    = "https://www.wonenbijbouwinvest.nl/huuraanbod?query=Utrecht&range=10&seniorservice=false&order=recent&size=50"
+	bouwInvestAllURL = "https://www.wonenbijbouwinvest.nl/huuraanbod?seniorservice=false&order=recent&size=50"
 )
 
 type Config struct {
-	ENVIRONMENT string
-	DEBUG_MODE  bool
+	Environment string `yaml:"environment"`
+	Debug       bool   `yaml:"debug"`
+	// Run the whole pipeline for every source but only log the alerts and
+	// database changes instead of sending and writing them.
+	DryRun bool `yaml:"dry_run"`
+
+	DBPath string `yaml:"db_path"`
+
+	Server    ServerConfig    `yaml:"server"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Browser   BrowserConfig   `yaml:"browser"`
+	Schedule  ScheduleConfig  `yaml:"schedule"`
+	Filters   FilterConfig    `yaml:"filters"`
+	Sources   []SourceConfig  `yaml:"sources"`
+	Profiles  []ProfileConfig `yaml:"profiles"`
+	Routing   RoutingConfig   `yaml:"routing"`
+	Scoring   ScoringConfig   `yaml:"scoring"`
+	Photos    PhotosConfig    `yaml:"photos"`
+	Archive   ArchiveConfig   `yaml:"archive"`
+	Notifiers NotifiersConfig `yaml:"notifiers"`
+
+	// Directory of the config file, relative paths in it are resolved against it
+	dir string
+}
 
-	DB_PATH string
+type ServerConfig struct {
+	// Address of the HTTP server for /metrics, the health checks and the API, empty disables it.
+	// Other than a loopback address it requires DashboardPassword.
+	Listen string `yaml:"listen"`
+	// A source without a successful scrape for this long makes /readyz fail.
+	// It is raised to three times the source interval when that is longer.
+	MaxScrapeAge time.Duration `yaml:"max_scrape_age"`
+	// Tokens that give access to the /api endpoints, the API is disabled without them.
+	APITokens []Secret `yaml:"api_tokens"`
+	// Password for the dashboard on /, asked with basic auth. Without it the
+	// dashboard is open, so the server has to listen on a loopback address.
+	DashboardPassword Secret `yaml:"dashboard_password"`
+}
+
+type LoggingConfig struct {
+	// Minimum level: debug, info, warn or error
+	Level string `yaml:"level"`
+	// Minimum level per module, e.g. BROWSER: warn
+	Modules map[string]string `yaml:"modules"`
+	// json or text
+	Format string        `yaml:"format"`
+	File   LogFileConfig `yaml:"file"`
+}
+
+// LogFileConfig enables writing logs to a file next to stdout.
+type LogFileConfig struct {
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+}
+
+type BrowserConfig struct {
+	Headless  bool   `yaml:"headless"`
+	ExecPath  string `yaml:"exec_path"`
+	UserAgent string `yaml:"user_agent"`
+}
+
+type ScheduleConfig struct {
+	// Interval between two runs of the same source, unless the source overrides it.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// FilterConfig decides which new listings are alerted on. Listings are
+// always stored, filters only apply to alerts. Matching is case-insensitive.
+type FilterConfig struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+type SourceConfig struct {
+	Name     string        `yaml:"name"`
+	URL      string        `yaml:"url"`
+	Username string        `yaml:"username"`
+	Password Secret        `yaml:"password"`
+	Interval time.Duration `yaml:"interval"`
+	Disabled bool          `yaml:"disabled"`
+	// Dry run only this source, e.g. while adding it
+	DryRun  bool          `yaml:"dry_run"`
+	Filters *FilterConfig `yaml:"filters"`
+	// Pages of results read at most, for sources with more than one page
+	MaxPages int `yaml:"max_pages"`
+	// Wait after scrolling or clicking to the next page for the listings to load
+	PageWait time.Duration `yaml:"page_wait"`
+	Details  DetailsConfig `yaml:"details"`
+	Consent  ConsentConfig `yaml:"consent"`
+}
+
+// ConsentConfig sets how the cookie banners of a source are clicked away.
+// Banners of common consent managers are recognised without it.
+type ConsentConfig struct {
+	// Leave cookie banners alone
+	Disabled bool `yaml:"disabled"`
+	// CSS selectors of the accept button of the site, tried before the known ones
+	Selectors []string `yaml:"selectors"`
+	// Set before the first page opens, so the site doesn't ask
+	Cookies []CookieConfig `yaml:"cookies"`
+}
+
+type CookieConfig struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+	// The host of the source URL by default, e.g. .example.nl for all subdomains
+	Domain string `yaml:"domain"`
+	// / by default
+	Path string `yaml:"path"`
+}
+
+// DetailsConfig enables visiting the page of every new listing of a source,
+// for the details that aren't on the overview.
+type DetailsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Detail pages visited per run at most, other new listings are stored without details
+	MaxPerRun int `yaml:"max_per_run"`
+	// CSS selector to wait for before reading the page, body by default
+	WaitFor string `yaml:"wait_for"`
+	// CSS selectors for when the defaults don't find the description or photos
+	Description string `yaml:"description"`
+	Photos      string `yaml:"photos"`
+}
+
+// ProfileConfig is a named selection of listings, e.g. what one person is
+// looking for. Profiles select listings for feeds, and when there are
+// profiles only new listings that match one of them are alerted on.
+type ProfileConfig struct {
+	Name string `yaml:"name"`
+	// Sources to include, all sources when empty
+	Sources []string     `yaml:"sources"`
+	Filters FilterConfig `yaml:"filters"`
+	Area    AreaConfig   `yaml:"area"`
+	// Places the listing should be close to, e.g. work
+	Commute []CommuteConfig `yaml:"commute"`
+	// Decide how matching listings are alerted, the first rule that matches applies
+	Rules []RuleConfig `yaml:"rules"`
+}
 
-	USER_NAME  string
-	REBO_PW    string
-	BEUMER_PW  string
-	VESTEDA_PW string
+// RuleConfig is an expression over a listing with what to do when it matches.
+type RuleConfig struct {
+	// e.g. price <= 1600 && area >= 60 && city == "Utrecht", see the rules package
+	When string `yaml:"when"`
+	// Notifiers to alert through: sms, email. All enabled ones when empty.
+	Channels []string `yaml:"channels"`
+	// high, normal or low. High alerts are sent on their own and listed first.
+	Priority string `yaml:"priority"`
+	// Don't alert on the listing for this profile
+	Ignore bool `yaml:"ignore"`
+
+	expr *rules.Expr
+}
+
+// Rule returns the first rule of the profile that matches the listing, or nil.
+func (p ProfileConfig) Rule(l rules.Listing) *RuleConfig {
+	for i := range p.Rules {
+		if p.Rules[i].Match(l) {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Match reports whether the listing satisfies the rule's expression.
+func (r RuleConfig) Match(l rules.Listing) bool {
+	return r.expr != nil && r.expr.Match(l)
+}
+
+// CommuteConfig is a destination travelled to from a listing.
+type CommuteConfig struct {
+	Name string  `yaml:"name"`
+	Lat  float64 `yaml:"lat"`
+	Lon  float64 `yaml:"lon"`
+	// bike, walk, transit or car
+	Mode string `yaml:"mode"`
+	// Longest acceptable travel time, listings further away aren't alerted. 0 is no limit.
+	MaxMinutes int `yaml:"max_minutes"`
+}
+
+// RoutingConfig sets how commute times are estimated.
+type RoutingConfig struct {
+	// straight, graph or osrm
+	Router string `yaml:"router"`
+	// The straight-line distance times this factor is the estimated route
+	Factor float64 `yaml:"factor"`
+	// Average speed per mode in km/h, transit including waiting and transfers
+	Speeds map[string]float64 `yaml:"speeds"`
+	// Graph written by `main commute import`, commute.graph in the data dir by default
+	Graph string `yaml:"graph"`
+	// Base URL of an OSRM compatible routing service, with a profile per mode
+	URL string `yaml:"url"`
+}
+
+// ScoringConfig weighs what makes a listing attractive. Every part scores
+// between 0 and 1, 0.5 when it isn't known, and the score is their weighted average.
+type ScoringConfig struct {
+	Weights ScoreWeights `yaml:"weights"`
+	// Rent per m² that scores 1, twice as much scores 0
+	PricePerM2 float64 `yaml:"price_per_m2"`
+	// Rooms that score 1, fewer score less
+	Rooms int `yaml:"rooms"`
+	// Shortest commute that scores 1, twice as long scores 0
+	CommuteMinutes int `yaml:"commute_minutes"`
+	// Days until available that score 1, twice as long scores 0
+	AvailableWithinDays int `yaml:"available_within_days"`
+	// Reliability per source between 0 and 1, sources not listed score 0.5
+	Sources map[string]float64 `yaml:"sources"`
+}
+
+// ScoreWeights are the relative weights of the parts of a score, 0 leaves a part out.
+type ScoreWeights struct {
+	PricePerM2   float64 `yaml:"price_per_m2"`
+	Rooms        float64 `yaml:"rooms"`
+	Distance     float64 `yaml:"distance"`
+	Availability float64 `yaml:"availability"`
+	Source       float64 `yaml:"source"`
+}
+
+// PhotosConfig enables downloading the photos found on detail pages of new
+// listings, see DetailsConfig.
+type PhotosConfig struct {
+	Download bool `yaml:"download"`
+	// The first photos of a listing that are downloaded
+	MaxPerListing int `yaml:"max_per_listing"`
+	// Larger photos are skipped
+	MaxSizeKB int `yaml:"max_size_kb"`
+	// Photos attached to an email alert, 0 attaches none
+	Attach int `yaml:"attach"`
+	// photos in the data dir by default
+	Dir string `yaml:"dir"`
+}
+
+// ArchiveConfig enables keeping the detail page of every new listing as it
+// was advertised, compressed.
+type ArchiveConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Also keep an MHTML file with the images and styles of the page
+	MHTML bool `yaml:"mhtml"`
+	// Archives older than this are removed, 0 keeps them
+	RetentionDays int `yaml:"retention_days"`
+	// archive in the data dir by default
+	Dir string `yaml:"dir"`
+}
+
+type NotifiersConfig struct {
+	SMS   SMSConfig   `yaml:"sms"`
+	Email EmailConfig `yaml:"email"`
+}
+
+type SMSConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	AccountSID string   `yaml:"account_sid"`
+	AuthToken  Secret   `yaml:"auth_token"`
+	From       string   `yaml:"from"`
+	To         []string `yaml:"to"`
+}
+
+type EmailConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Server   string   `yaml:"server"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password Secret   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// Default returns the configuration used when no config file is present.
+func Default() *Config {
+	return &Config{
+		Environment: "production",
+		DBPath:      "/app/data/properties.db",
+		Server: ServerConfig{
+			Listen:       "127.0.0.1:8080",
+			MaxScrapeAge: 15 * time.Minute,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+			File: LogFileConfig{
+				MaxSizeMB:  10,
+				MaxBackups: 5,
+			},
+		},
+		Browser: BrowserConfig{
+			Headless: true,
+		},
+		Schedule: ScheduleConfig{
+			Interval: 30 * time.Second,
+		},
+		Sources: []SourceConfig{
+			{Name: "REBO", URL: "https://rebowonenhuur.nl/login"},
+			{Name: "VESTEDA", URL: "https://hurenbij.vesteda.com/login"},
+			{Name: "BOUWINVEST", URL: bouwInvestURL},
+			{Name: "BEUMER", URL: "https://www.beumer.nl/huurwoningen/?search=Utrecht&status%5B0%5D=te-huur"},
+		},
+		Routing: RoutingConfig{
+			Router: "straight",
+			Factor: 1.3,
+			Speeds: map[string]float64{"bike": 15, "walk": 5, "transit": 20, "car": 30},
+		},
+		Scoring: ScoringConfig{
+			Weights: ScoreWeights{
+				PricePerM2:   1,
+				Rooms:        1,
+				Distance:     1,
+				Availability: 0.5,
+				Source:       0.5,
+			},
+			PricePerM2:          20,
+			Rooms:               3,
+			CommuteMinutes:      20,
+			AvailableWithinDays: 30,
+		},
+		Photos: PhotosConfig{
+			MaxPerListing: 5,
+			MaxSizeKB:     2048,
+			Attach:        3,
+		},
+		Archive: ArchiveConfig{
+			RetentionDays: 365,
+		},
+		Notifiers: NotifiersConfig{
+			Email: EmailConfig{Port: 587},
+		},
+	}
+}
 
-	TWILIO_SID          string
-	TWILIO_TOKEN        string
-	TWILIO_PHONE_NUMBER string
-	YOUR_PHONE_NUMBER   string
-	SMTP_SERVER         string
-	SMTP_PORT           int
-	SMTP_USERNAME       string
-	SMTP_PASSWORD       string
-	TO_EMAIL            string
-	FROM_EMAIL          string
+// Path returns the config file location and whether it was set explicitly.
+func Path() (string, bool) {
+	if p := os.Getenv("CONFIG_PATH"); p != "" {
+		return p, true
+	}
+	return DefaultPath, false
 }
 
-func LoadConfig() (*Config, error) {
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found: %v", err)
+// Load reads the YAML file at path on top of the defaults, applies env var
+// overrides and validates the result. A missing file is only an error when
+// required is set, so a plain .env setup keeps working.
+func Load(path string, required bool) (*Config, error) {
+	// Load .env file if it exists, again on every reload
+	if err := loadDotenv(); err != nil {
+		log.Printf("Warning: failed to load .env file: %v", err)
 	}
 
-	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
-	if err != nil {
-		log.Fatalf("Failed to convert SMTP_PORT to int: %v", err)
+	config := Default()
+	config.dir = filepath.Dir(path)
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := Parse(data, config); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !required:
+		// env vars only
+	default:
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	config := &Config{
-		ENVIRONMENT: os.Getenv("ENVIRONMENT"),
-		DEBUG_MODE:  os.Getenv("DEBUG_MODE") == "true",
+	config.normalize()
+
+	if err := applyEnv(config, err == nil); err != nil {
+		return nil, err
+	}
 
-		DB_PATH: os.Getenv("DB_PATH"),
+	// Outside of docker, keep the data next to the code
+	if config.Environment == "development" {
+		config.DBPath = "./data/properties.db"
+	}
 
-		USER_NAME:  os.Getenv("USER_NAME"),
-		REBO_PW:    os.Getenv("REBO_PW"),
-		BEUMER_PW:  os.Getenv("BEUMER_PW"),
-		VESTEDA_PW: os.Getenv("VESTEDA_PW"),
+	if err := config.resolveSecrets(); err != nil {
+		return nil, err
+	}
 
-		TWILIO_SID:          os.Getenv("TWILIO_SID"),
-		TWILIO_TOKEN:        os.Getenv("TWILIO_TOKEN"),
-		TWILIO_PHONE_NUMBER: os.Getenv("TWILIO_PHONE_NUMBER"),
-		YOUR_PHONE_NUMBER:   os.Getenv("YOUR_PHONE_NUMBER"),
-		SMTP_SERVER:         os.Getenv("SMTP_SERVER"),
-		SMTP_PORT:           port,
-		SMTP_USERNAME:       os.Getenv("SMTP_USERNAME"),
-		SMTP_PASSWORD:       os.Getenv("SMTP_PASSWORD"),
-		TO_EMAIL:            os.Getenv("TO_EMAIL"),
-		FROM_EMAIL:          os.Getenv("FROM_EMAIL"),
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
+
+// Parse decodes YAML into config. Unknown keys are rejected so typos don't
+// silently fall back to defaults.
+func Parse(data []byte, config *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(config); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Config) normalize() {
+	for i := range c.Sources {
+		c.Sources[i].Name = strings.ToUpper(strings.TrimSpace(c.Sources[i].Name))
+		if details := &c.Sources[i].Details; details.MaxPerRun == 0 {
+			details.MaxPerRun = 10
+		}
+	}
+	for i := range c.Profiles {
+		c.Profiles[i].Name = strings.TrimSpace(c.Profiles[i].Name)
+		for j := range c.Profiles[i].Sources {
+			c.Profiles[i].Sources[j] = strings.ToUpper(strings.TrimSpace(c.Profiles[i].Sources[j]))
+		}
+	}
+	for i, s := range c.Sources {
+		if s.URL == bouwInvestURL && c.limitedByAreas(s.Name) {
+			c.Sources[i].URL = bouwInvestAllURL
+		}
+	}
+	if len(c.Scoring.Sources) > 0 {
+		sources := make(map[string]float64, len(c.Scoring.Sources))
+		for name, reliability := range c.Scoring.Sources {
+			sources[strings.ToUpper(strings.TrimSpace(name))] = reliability
+		}
+		c.Scoring.Sources = sources
+	}
+}
+
+// limitedByAreas reports whether the listings of source are only alerted in
+// profile areas: there are profiles, and the ones with source have an area.
+func (c *Config) limitedByAreas(source string) bool {
+	if len(c.Profiles) == 0 {
+		return false
+	}
+	for _, p := range c.Profiles {
+		if (len(p.Sources) == 0 || slices.Contains(p.Sources, source)) && p.Area.Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// DataDir is the directory of the database, where other data is stored too.
+func (c *Config) DataDir() string {
+	return filepath.Dir(c.DBPath)
+}
+
+// SnapshotDir is where screenshots and HTML of failed scrapes are stored.
+func (c *Config) SnapshotDir() string {
+	return filepath.Join(c.DataDir(), "snapshots")
+}
+
+// PhotoDir is where downloaded photos are stored.
+func (c *Config) PhotoDir() string {
+	if c.Photos.Dir != "" {
+		return c.Photos.Dir
+	}
+	return filepath.Join(c.DataDir(), "photos")
+}
+
+// ArchiveDir is where archived listing pages are stored.
+func (c *Config) ArchiveDir() string {
+	if c.Archive.Dir != "" {
+		return c.Archive.Dir
+	}
+	return filepath.Join(c.DataDir(), "archive")
+}
+
+// GraphPath is where the routing graph is stored.
+func (c *Config) GraphPath() string {
+	if c.Routing.Graph != "" {
+		return c.Routing.Graph
+	}
+	return filepath.Join(c.DataDir(), "commute.graph")
+}
+
+// Source returns the config of the named source, or nil if it is not configured.
+func (c *Config) Source(name string) *SourceConfig {
+	for i := range c.Sources {
+		if c.Sources[i].Name == name {
+			return &c.Sources[i]
+		}
+	}
+	return nil
+}
+
+// EnabledSources returns the sources that are not disabled, in config order.
+func (c *Config) EnabledSources() []SourceConfig {
+	sources := make([]SourceConfig, 0, len(c.Sources))
+	for _, s := range c.Sources {
+		if !s.Disabled {
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
+// SourceInterval returns the interval of the source, falling back to the global schedule.
+func (c *Config) SourceInterval(s SourceConfig) time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return c.Schedule.Interval
+}
+
+// SourceDryRun reports whether the source runs without alerts and database writes.
+func (c *Config) SourceDryRun(s SourceConfig) bool {
+	return c.DryRun || s.DryRun
+}
+
+// SourceFilters returns the filters of the source, falling back to the global filters.
+func (c *Config) SourceFilters(s SourceConfig) FilterConfig {
+	if s.Filters != nil {
+		return *s.Filters
+	}
+	return c.Filters
+}
+
+// Profile returns the named profile, or nil if it is not configured.
+func (c *Config) Profile(name string) *ProfileConfig {
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			return &c.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// Match reports whether a listing of source with the given address and
+// location belongs to the profile. A nil location is unknown.
+func (p ProfileConfig) Match(source, address string, location *geo.Point) bool {
+	if len(p.Sources) > 0 && !slices.Contains(p.Sources, source) {
+		return false
+	}
+	return p.Filters.Match(address) && p.Area.Contains(location)
+}
+
+// Match reports whether text passes the include and exclude keywords.
+func (f FilterConfig) Match(text string) bool {
+	text = strings.ToLower(text)
+	for _, keyword := range f.Exclude {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, keyword := range f.Include {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
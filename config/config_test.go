@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestBouwInvestURL(t *testing.T) {
+	utrecht := AreaConfig{Within: []CircleConfig{{Name: "Utrecht", Lat: 52.09, Lon: 5.11, RadiusKM: 10}}}
+	tests := []struct {
+		name     string
+		profiles []ProfileConfig
+		want     string
+	}{
+		{name: "no profiles", want: bouwInvestURL},
+		{name: "profile without area", profiles: []ProfileConfig{{Name: "anna"}}, want: bouwInvestURL},
+		{name: "profile with area", profiles: []ProfileConfig{{Name: "anna", Area: utrecht}}, want: bouwInvestAllURL},
+		{
+			name:     "one of the profiles without area",
+			profiles: []ProfileConfig{{Name: "anna", Area: utrecht}, {Name: "ben", Sources: []string{"bouwinvest"}}},
+			want:     bouwInvestURL,
+		},
+		{
+			name:     "profile without area for other sources",
+			profiles: []ProfileConfig{{Name: "anna", Area: utrecht}, {Name: "ben", Sources: []string{"REBO"}}},
+			want:     bouwInvestAllURL,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.Profiles = tt.profiles
+			c.normalize()
+			if got := c.Source("BOUWINVEST").URL; got != tt.want {
+				t.Errorf("URL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBouwInvestURLConfigured(t *testing.T) {
+	c := Default()
+	const url = "https://www.wonenbijbouwinvest.nl/huuraanbod?query=Amersfoort&range=5"
+	c.Source("BOUWINVEST").URL = url
+	c.Profiles = []ProfileConfig{{Name: "anna", Area: AreaConfig{GeoJSON: []string{"amersfoort.geojson"}}}}
+	c.normalize()
+	if got := c.Source("BOUWINVEST").URL; got != url {
+		t.Errorf("URL = %q, want the configured one", got)
+	}
+}
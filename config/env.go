@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// The .env file is read again on every load, so a reload picks up edits.
+// Vars that were set in the environment before it was first read win over
+// it, like godotenv.Load.
+var dotenv struct {
+	sync.Mutex
+	process map[string]bool   // set in the environment itself
+	loaded  map[string]string // set from the .env file
+}
+
+// loadDotenv sets the vars of the .env file that aren't set in the
+// environment itself, and unsets the ones that were removed from it.
+func loadDotenv() error {
+	dotenv.Lock()
+	defer dotenv.Unlock()
+
+	if dotenv.process == nil {
+		dotenv.process = make(map[string]bool)
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			dotenv.process[name] = true
+		}
+	}
+
+	vars, err := godotenv.Read(".env")
+	if errors.Is(err, os.ErrNotExist) {
+		vars, err = map[string]string{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	for name := range dotenv.loaded {
+		if _, ok := vars[name]; !ok && !dotenv.process[name] {
+			os.Unsetenv(name)
+		}
+	}
+	for name, value := range vars {
+		if !dotenv.process[name] {
+			os.Setenv(name, value)
+		}
+	}
+	dotenv.loaded = vars
+	return nil
+}
+
+// envLookup reads env vars. Every var can also be provided as a file by
+// setting NAME_FILE to its path, which is how Docker secrets are mounted.
+type envLookup struct {
+	errs []error
+}
+
+func (e *envLookup) get(name string) (string, bool) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		return v, true
+	}
+	path, ok := os.LookupEnv(name + "_FILE")
+	if !ok || path == "" {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s_FILE: %w", name, err))
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
+
+// applyEnv lets the env vars of the original .env setup override the config
+// file. Without a config file, providing credentials enables a notifier.
+func applyEnv(c *Config, fromFile bool) error {
+	env := &envLookup{}
+
+	if v, ok := env.get("ENVIRONMENT"); ok {
+		c.Environment = v
+	}
+	if v, ok := env.get("DEBUG_MODE"); ok {
+		c.Debug = v == "true"
+	}
+	if v, ok := env.get("DRY_RUN"); ok {
+		c.DryRun = v == "true"
+	}
+	if v, ok := env.get("DB_PATH"); ok {
+		c.DBPath = v
+	}
+	if v, ok := env.get("LISTEN_ADDR"); ok {
+		c.Server.Listen = v
+	}
+	if v, ok := env.get("API_TOKEN"); ok {
+		c.Server.APITokens = []Secret{Secret(v)}
+	}
+	if v, ok := env.get("DASHBOARD_PASSWORD"); ok {
+		c.Server.DashboardPassword = Secret(v)
+	}
+	if v, ok := env.get("LOG_LEVEL"); ok {
+		c.Logging.Level = v
+	}
+	if v, ok := env.get("LOG_FORMAT"); ok {
+		c.Logging.Format = v
+	}
+
+	for i := range c.Sources {
+		s := &c.Sources[i]
+		if v, ok := env.get("USER_NAME"); ok {
+			s.Username = v
+		}
+		// e.g. REBO_PW
+		if v, ok := env.get(strings.ToUpper(s.Name) + "_PW"); ok {
+			s.Password = Secret(v)
+		}
+	}
+
+	sms := &c.Notifiers.SMS
+	if v, ok := env.get("TWILIO_SID"); ok {
+		sms.AccountSID = v
+		if !fromFile {
+			sms.Enabled = true
+		}
+	}
+	if v, ok := env.get("TWILIO_TOKEN"); ok {
+		sms.AuthToken = Secret(v)
+	}
+	if v, ok := env.get("TWILIO_PHONE_NUMBER"); ok {
+		sms.From = v
+	}
+	if v, ok := env.get("YOUR_PHONE_NUMBER"); ok {
+		sms.To = splitList(v)
+	}
+
+	email := &c.Notifiers.Email
+	if v, ok := env.get("SMTP_SERVER"); ok {
+		email.Server = v
+		if !fromFile {
+			email.Enabled = true
+		}
+	}
+	if v, ok := env.get("SMTP_PORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			env.errs = append(env.errs, fmt.Errorf("SMTP_PORT: %q is not a number", v))
+		}
+		email.Port = port
+	}
+	if v, ok := env.get("SMTP_USERNAME"); ok {
+		email.Username = v
+	}
+	if v, ok := env.get("SMTP_PASSWORD"); ok {
+		email.Password = Secret(v)
+	}
+	if v, ok := env.get("FROM_EMAIL"); ok {
+		email.From = v
+	}
+	if v, ok := env.get("TO_EMAIL"); ok {
+		email.To = splitList(v)
+	}
+
+	return errors.Join(env.errs...)
+}
+
+// splitList splits a comma separated env var value.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoadDotenvReload(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(dir)
+		dotenv.process, dotenv.loaded = nil, nil
+		os.Unsetenv("HW_TEST_FROM_FILE")
+	})
+	dotenv.process, dotenv.loaded = nil, nil
+	t.Setenv("HW_TEST_FROM_PROCESS", "process")
+
+	steps := []struct {
+		dotenv      string
+		wantFile    string
+		wantFileSet bool
+	}{
+		{dotenv: "HW_TEST_FROM_FILE=one\nHW_TEST_FROM_PROCESS=file\n", wantFile: "one", wantFileSet: true},
+		{dotenv: "HW_TEST_FROM_FILE=two\n", wantFile: "two", wantFileSet: true},
+		{dotenv: "", wantFileSet: false},
+	}
+	for i, step := range steps {
+		if err := os.WriteFile(".env", []byte(step.dotenv), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadDotenv(); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := os.LookupEnv("HW_TEST_FROM_FILE")
+		if got != step.wantFile || ok != step.wantFileSet {
+			t.Errorf("step %d: HW_TEST_FROM_FILE = %q (set %v), want %q (set %v)", i, got, ok, step.wantFile, step.wantFileSet)
+		}
+		if got := os.Getenv("HW_TEST_FROM_PROCESS"); got != "process" {
+			t.Errorf("step %d: HW_TEST_FROM_PROCESS = %q, want the environment to win", i, got)
+		}
+	}
+}
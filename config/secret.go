@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Secret holds a credential. In YAML it can be written inline or as a
+// reference: "env:NAME" reads the env var NAME and "file:/path" reads the
+// (trimmed) file contents, e.g. a Docker secret. References are resolved
+// when the config is loaded, and only for enabled sources and notifiers.
+// String never reveals the value so a Secret can be logged or printed safely.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+func resolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret references env var %s which is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "file:"):
+		path := strings.TrimPrefix(ref, "file:")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return ref, nil
+	}
+}
+
+// resolveSecrets replaces secret references with their values.
+func (c *Config) resolveSecrets() error {
+	v := &validator{}
+	resolve := func(field string, s *Secret) {
+		value, err := resolveSecret(string(*s))
+		if err != nil {
+			v.addf(field, "%v", err)
+			return
+		}
+		*s = Secret(value)
+	}
+
+	for i := range c.Sources {
+		if !c.Sources[i].Disabled {
+			resolve(fmt.Sprintf("sources[%d].password", i), &c.Sources[i].Password)
+		}
+	}
+	for i := range c.Server.APITokens {
+		resolve(fmt.Sprintf("server.api_tokens[%d]", i), &c.Server.APITokens[i])
+	}
+	resolve("server.dashboard_password", &c.Server.DashboardPassword)
+	if c.Notifiers.SMS.Enabled {
+		resolve("notifiers.sms.auth_token", &c.Notifiers.SMS.AuthToken)
+	}
+	if c.Notifiers.Email.Enabled {
+		resolve("notifiers.email.password", &c.Notifiers.Email.Password)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// SecretValues returns the values of all secrets in the config, so they can
+// be masked in logs.
+func (c *Config) SecretValues() []string {
+	var values []string
+	add := func(s Secret) {
+		if s != "" {
+			values = append(values, s.Value())
+		}
+	}
+	for _, s := range c.Sources {
+		add(s.Password)
+	}
+	for _, token := range c.Server.APITokens {
+		add(token)
+	}
+	add(c.Server.DashboardPassword)
+	add(c.Notifiers.SMS.AuthToken)
+	add(c.Notifiers.Email.Password)
+	return values
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Store holds the loaded config. It is loaded once and handed to everything
+// that needs it; Reload swaps in a new config atomically, so a reader always
+// sees a complete, validated config.
+type Store struct {
+	path     string
+	required bool
+	checks   []func(*Config) error
+
+	current atomic.Pointer[Config]
+	mu      sync.Mutex
+}
+
+// ReloadFunc is called after every reload attempt. On error the previous
+// config stays active and new is nil.
+type ReloadFunc func(old, new *Config, err error)
+
+// NewStore loads the config at path. The checks are run on every load, in
+// addition to Validate, and can reject a config.
+func NewStore(path string, required bool, checks ...func(*Config) error) (*Store, error) {
+	s := &Store{
+		path:     path,
+		required: required,
+		checks:   checks,
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Config returns the active config. Callers should get it once per unit of
+// work and not mix values from different calls.
+func (s *Store) Config() *Config {
+	return s.current.Load()
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Reload loads and validates the config file and makes it the active config.
+// An invalid config is returned as an error and leaves the active one in place.
+func (s *Store) Reload() (*Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := Load(s.path, s.required)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range s.checks {
+		if err := check(c); err != nil {
+			return nil, err
+		}
+	}
+
+	s.current.Store(c)
+	return c, nil
+}
+
+// Watch reloads the config on SIGHUP and whenever the config file changes,
+// until ctx is done.
+func (s *Store) Watch(ctx context.Context, onReload ReloadFunc) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// Watch the directory, editors and Kubernetes/Docker config mounts
+	// replace the file instead of writing to it.
+	dir := filepath.Dir(s.path)
+	if err := watcher.Add(dir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// Changes usually come in bursts, wait for them to settle
+	const settle = 500 * time.Millisecond
+	debounce := time.NewTimer(settle)
+	debounce.Stop()
+
+	reload := func() {
+		old := s.Config()
+		c, err := s.Reload()
+		onReload(old, c, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			reload()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == filepath.Clean(s.path) && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				debounce.Reset(settle)
+			}
+		case <-debounce.C:
+			reload()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			onReload(s.Config(), nil, fmt.Errorf("file watcher: %w", err))
+		}
+	}
+}
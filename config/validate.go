@@ -0,0 +1,358 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+
+	"huurwoning/rules"
+)
+
+// ValidationError lists every problem found in a config, so they can all be
+// fixed in one go.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(field, format string, args ...any) {
+	v.problems = append(v.problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+// loginSources are the sources whose listings are only shown after logging in.
+var loginSources = map[string]bool{"REBO": true, "VESTEDA": true}
+
+// Validate checks the config for missing or malformed values. Notifiers are
+// only checked when they are enabled.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if c.DBPath == "" {
+		v.addf("db_path", "is required")
+	}
+	if c.Schedule.Interval <= 0 {
+		v.addf("schedule.interval", "must be a positive duration, e.g. 30s")
+	}
+
+	if c.Server.Listen != "" {
+		if host, _, err := net.SplitHostPort(c.Server.Listen); err != nil {
+			v.addf("server.listen", "must be host:port or :port, got %q", c.Server.Listen)
+		} else if !loopback(host) && c.Server.DashboardPassword.Value() == "" {
+			v.addf("server.listen", "%q is reachable from other hosts, set server.dashboard_password or listen on 127.0.0.1", c.Server.Listen)
+		}
+	}
+	if c.Server.MaxScrapeAge <= 0 {
+		v.addf("server.max_scrape_age", "must be a positive duration, e.g. 15m")
+	}
+	for i, token := range c.Server.APITokens {
+		if len(token.Value()) < 16 {
+			v.addf(fmt.Sprintf("server.api_tokens[%d]", i), "must be at least 16 characters")
+		}
+	}
+	v.logging(c.Logging)
+	v.filters("filters", c.Filters)
+
+	seen := make(map[string]bool)
+	for i, s := range c.Sources {
+		field := fmt.Sprintf("sources[%d]", i)
+		if s.Name == "" {
+			v.addf(field+".name", "is required")
+		} else if seen[s.Name] {
+			v.addf(field+".name", "duplicate source %q", s.Name)
+		}
+		seen[s.Name] = true
+
+		if err := validateURL(s.URL); err != nil {
+			v.addf(field+".url", "%v", err)
+		}
+		if loginSources[s.Name] && !s.Disabled {
+			if s.Username == "" {
+				v.addf(field+".username", "is required to log in, set it or USER_NAME")
+			}
+			if s.Password == "" {
+				v.addf(field+".password", "is required to log in, set it or %s_PW", s.Name)
+			}
+		}
+		if s.Interval < 0 {
+			v.addf(field+".interval", "must not be negative")
+		}
+		if s.Filters != nil {
+			v.filters(field+".filters", *s.Filters)
+		}
+		if s.MaxPages < 0 {
+			v.addf(field+".max_pages", "must not be negative")
+		}
+		if s.PageWait < 0 {
+			v.addf(field+".page_wait", "must not be negative")
+		}
+		for j, cookie := range s.Consent.Cookies {
+			if cookie.Name == "" {
+				v.addf(fmt.Sprintf("%s.consent.cookies[%d].name", field, j), "is required")
+			}
+		}
+		if s.Details.MaxPerRun < 0 {
+			v.addf(field+".details.max_per_run", "must not be negative")
+		}
+	}
+
+	profiles := make(map[string]bool)
+	for i, p := range c.Profiles {
+		field := fmt.Sprintf("profiles[%d]", i)
+		if p.Name == "" {
+			v.addf(field+".name", "is required")
+		} else if profiles[p.Name] {
+			v.addf(field+".name", "duplicate profile %q", p.Name)
+		}
+		profiles[p.Name] = true
+
+		for j, source := range p.Sources {
+			if !seen[source] {
+				v.addf(fmt.Sprintf("%s.sources[%d]", field, j), "unknown source %q", source)
+			}
+		}
+		v.filters(field+".filters", p.Filters)
+		v.area(field+".area", &c.Profiles[i].Area, c.dir)
+
+		destinations := make(map[string]bool)
+		for j, d := range p.Commute {
+			commute := fmt.Sprintf("%s.commute[%d]", field, j)
+			if d.Name == "" {
+				v.addf(commute+".name", "is required")
+			} else if destinations[d.Name] {
+				v.addf(commute+".name", "duplicate destination %q", d.Name)
+			}
+			destinations[d.Name] = true
+			if !validPoint(d.Lat, d.Lon) {
+				v.addf(commute, "needs a valid lat and lon")
+			}
+			if _, ok := c.Routing.Speeds[d.Mode]; !ok {
+				v.addf(commute+".mode", "must be one of the modes in routing.speeds, got %q", d.Mode)
+			}
+			if d.MaxMinutes < 0 {
+				v.addf(commute+".max_minutes", "must not be negative")
+			}
+		}
+
+		for j := range p.Rules {
+			v.rule(fmt.Sprintf("%s.rules[%d]", field, j), &c.Profiles[i].Rules[j])
+		}
+	}
+	v.routing(c.Routing)
+	v.scoring(c.Scoring, seen)
+
+	if c.Photos.MaxPerListing < 0 {
+		v.addf("photos.max_per_listing", "must not be negative")
+	}
+	if c.Photos.Download && c.Photos.MaxSizeKB <= 0 {
+		v.addf("photos.max_size_kb", "must be positive")
+	}
+	if c.Photos.Attach < 0 {
+		v.addf("photos.attach", "must not be negative")
+	}
+	if c.Archive.RetentionDays < 0 {
+		v.addf("archive.retention_days", "must not be negative")
+	}
+
+	sms := c.Notifiers.SMS
+	if sms.Enabled {
+		if sms.AccountSID == "" {
+			v.addf("notifiers.sms.account_sid", "is required when sms is enabled")
+		}
+		if sms.AuthToken == "" {
+			v.addf("notifiers.sms.auth_token", "is required when sms is enabled")
+		}
+		if sms.From == "" {
+			v.addf("notifiers.sms.from", "is required when sms is enabled")
+		}
+		if len(sms.To) == 0 {
+			v.addf("notifiers.sms.to", "needs at least one phone number when sms is enabled")
+		}
+	}
+
+	email := c.Notifiers.Email
+	if email.Enabled {
+		if email.Server == "" {
+			v.addf("notifiers.email.server", "is required when email is enabled")
+		}
+		if email.Port <= 0 || email.Port > 65535 {
+			v.addf("notifiers.email.port", "must be between 1 and 65535, got %d", email.Port)
+		}
+		if _, err := mail.ParseAddress(email.From); err != nil {
+			v.addf("notifiers.email.from", "invalid address %q", email.From)
+		}
+		if len(email.To) == 0 {
+			v.addf("notifiers.email.to", "needs at least one address when email is enabled")
+		}
+		for i, to := range email.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				v.addf(fmt.Sprintf("notifiers.email.to[%d]", i), "invalid address %q", to)
+			}
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (v *validator) logging(l LoggingConfig) {
+	if !validLevel(l.Level) {
+		v.addf("logging.level", "must be debug, info, warn or error, got %q", l.Level)
+	}
+	for module, level := range l.Modules {
+		if !validLevel(level) {
+			v.addf("logging.modules."+module, "must be debug, info, warn or error, got %q", level)
+		}
+	}
+	if l.Format != "json" && l.Format != "text" {
+		v.addf("logging.format", "must be json or text, got %q", l.Format)
+	}
+	if l.File.MaxSizeMB < 0 {
+		v.addf("logging.file.max_size_mb", "must not be negative")
+	}
+	if l.File.MaxBackups < 0 {
+		v.addf("logging.file.max_backups", "must not be negative")
+	}
+}
+
+func (v *validator) routing(r RoutingConfig) {
+	switch r.Router {
+	case "straight", "graph":
+	case "osrm":
+		if err := validateURL(r.URL); err != nil {
+			v.addf("routing.url", "%v", err)
+		}
+	default:
+		v.addf("routing.router", "must be straight, graph or osrm, got %q", r.Router)
+	}
+	if r.Factor < 1 {
+		v.addf("routing.factor", "must be at least 1, got %g", r.Factor)
+	}
+	for _, mode := range slices.Sorted(maps.Keys(r.Speeds)) {
+		if r.Speeds[mode] <= 0 {
+			v.addf("routing.speeds."+mode, "must be positive")
+		}
+	}
+}
+
+func (v *validator) scoring(s ScoringConfig, sources map[string]bool) {
+	weights := []struct {
+		name   string
+		weight float64
+	}{
+		{"price_per_m2", s.Weights.PricePerM2},
+		{"rooms", s.Weights.Rooms},
+		{"distance", s.Weights.Distance},
+		{"availability", s.Weights.Availability},
+		{"source", s.Weights.Source},
+	}
+	for _, w := range weights {
+		if w.weight < 0 {
+			v.addf("scoring.weights."+w.name, "must not be negative")
+		}
+	}
+	if s.PricePerM2 <= 0 {
+		v.addf("scoring.price_per_m2", "must be positive")
+	}
+	if s.Rooms <= 0 {
+		v.addf("scoring.rooms", "must be positive")
+	}
+	if s.CommuteMinutes <= 0 {
+		v.addf("scoring.commute_minutes", "must be positive")
+	}
+	if s.AvailableWithinDays <= 0 {
+		v.addf("scoring.available_within_days", "must be positive")
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Sources)) {
+		reliability := s.Sources[name]
+		if !sources[name] {
+			v.addf("scoring.sources."+name, "unknown source")
+		}
+		if reliability < 0 || reliability > 1 {
+			v.addf("scoring.sources."+name, "must be between 0 and 1, got %g", reliability)
+		}
+	}
+}
+
+// rule checks the actions of r and compiles its expression.
+func (v *validator) rule(field string, r *RuleConfig) {
+	expr, err := rules.Compile(r.When)
+	if err != nil {
+		v.addf(field+".when", "%v in %q", err, r.When)
+	}
+	r.expr = expr
+
+	for i, channel := range r.Channels {
+		if channel != "sms" && channel != "email" {
+			v.addf(fmt.Sprintf("%s.channels[%d]", field, i), "must be sms or email, got %q", channel)
+		}
+	}
+	switch r.Priority {
+	case "", "high", "normal", "low":
+	default:
+		v.addf(field+".priority", "must be high, normal or low, got %q", r.Priority)
+	}
+	if r.Ignore && (len(r.Channels) > 0 || r.Priority != "") {
+		v.addf(field, "an ignore rule can't have channels or a priority")
+	}
+}
+
+func validPoint(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 && (lat != 0 || lon != 0)
+}
+
+func validLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "warning", "error":
+		return true
+	}
+	return false
+}
+
+func (v *validator) filters(field string, f FilterConfig) {
+	for i, keyword := range f.Include {
+		if strings.TrimSpace(keyword) == "" {
+			v.addf(fmt.Sprintf("%s.include[%d]", field, i), "must not be empty")
+		}
+	}
+	for i, keyword := range f.Exclude {
+		if strings.TrimSpace(keyword) == "" {
+			v.addf(fmt.Sprintf("%s.exclude[%d]", field, i), "must not be empty")
+		}
+	}
+}
+
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http(s) url, got %q", raw)
+	}
+	return nil
+}
+
+// loopback reports whether host only accepts connections from this machine.
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateListen(t *testing.T) {
+	tests := []struct {
+		listen   string
+		password Secret
+		wantErr  bool
+	}{
+		{listen: "", wantErr: false},
+		{listen: "127.0.0.1:8080", wantErr: false},
+		{listen: "localhost:8080", wantErr: false},
+		{listen: "[::1]:8080", wantErr: false},
+		{listen: ":8080", wantErr: true},
+		{listen: "0.0.0.0:8080", wantErr: true},
+		{listen: "192.168.1.10:8080", wantErr: true},
+		{listen: ":8080", password: "secret", wantErr: false},
+		{listen: "8080", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.listen, func(t *testing.T) {
+			c := Default()
+			c.Server.Listen = tt.listen
+			c.Server.DashboardPassword = tt.password
+			err := c.Validate()
+			gotErr := err != nil && strings.Contains(err.Error(), "server.listen")
+			if gotErr != tt.wantErr {
+				t.Errorf("Validate() = %v, want a server.listen error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   SourceConfig
+		wantErrs []string
+	}{
+		{
+			name:   "credentials",
+			source: SourceConfig{Name: "REBO", URL: "https://rebowonenhuur.nl/login", Username: "anna@example.com", Password: "secret"},
+		},
+		{
+			name:     "no username",
+			source:   SourceConfig{Name: "REBO", URL: "https://rebowonenhuur.nl/login", Password: "secret"},
+			wantErrs: []string{"sources[0].username"},
+		},
+		{
+			name:     "no credentials",
+			source:   SourceConfig{Name: "VESTEDA", URL: "https://hurenbij.vesteda.com/login"},
+			wantErrs: []string{"sources[0].username", "sources[0].password: is required to log in, set it or VESTEDA_PW"},
+		},
+		{
+			name:   "disabled",
+			source: SourceConfig{Name: "VESTEDA", URL: "https://hurenbij.vesteda.com/login", Disabled: true},
+		},
+		{
+			name:   "no login",
+			source: SourceConfig{Name: "BEUMER", URL: "https://www.beumer.nl/huurwoningen/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.Sources = []SourceConfig{tt.source}
+			err := c.Validate()
+			if len(tt.wantErrs) == 0 && err != nil {
+				t.Fatalf("Validate() = %v, want no error", err)
+			}
+			for _, want := range tt.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
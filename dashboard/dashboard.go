@@ -0,0 +1,263 @@
+package dashboard
+
+import (
+	"crypto/subtle"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"huurwoning/config"
+	"huurwoning/db"
+	"huurwoning/logger"
+)
+
+//go:embed templates static
+var assets embed.FS
+
+const (
+	pageSize   = 25
+	chartDays  = 30
+	errorCount = 10
+)
+
+// Dashboard is a small server-rendered website over the stored data, for
+// browsing listings without waiting for alerts.
+type Dashboard struct {
+	store  *config.Store
+	db     *db.Database
+	logger *logger.Logger
+	pages  map[string]*template.Template
+}
+
+var funcs = template.FuncMap{
+	"time": func(t time.Time) string {
+		return t.Local().Format("02-01-2006 15:04")
+	},
+	"price": func(price int) string {
+		if price <= 0 {
+			return ""
+		}
+		return fmt.Sprintf("€ %d", price)
+	},
+	"score": func(p db.Property) string {
+		if p.ScoreDetails == "" {
+			return ""
+		}
+		return fmt.Sprintf("%.2f", p.Score)
+	},
+	"percent": func(count, max int) int {
+		if max == 0 {
+			return 0
+		}
+		return count * 100 / max
+	},
+}
+
+func New(store *config.Store, db *db.Database, globalLogger *logger.GlobalLogger) (*Dashboard, error) {
+	d := &Dashboard{
+		store:  store,
+		db:     db,
+		logger: globalLogger.Logger("DASHBOARD"),
+		pages:  make(map[string]*template.Template),
+	}
+
+	for _, page := range []string{"index.html", "listings.html"} {
+		tmpl, err := template.New(page).Funcs(funcs).ParseFS(assets, "templates/layout.html", "templates/"+page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", page, err)
+		}
+		d.pages[page] = tmpl
+	}
+
+	return d, nil
+}
+
+// Handler returns the routes of the dashboard, served from /.
+func (d *Dashboard) Handler() http.Handler {
+	static, _ := fs.Sub(assets, "static")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.index)
+	mux.HandleFunc("GET /listings", d.listings)
+	mux.HandleFunc("GET /snapshots/{name}", d.snapshot)
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	return d.Protect(mux)
+}
+
+// Protect asks for server.dashboard_password when it is set. Any user name is
+// accepted. It also protects pages served next to the dashboard, like the feed.
+func (d *Dashboard) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		password := d.store.Config().Server.DashboardPassword
+		if password != "" {
+			_, given, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(password.Value())) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="huurwoning"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type sourceStatus struct {
+	Name           string
+	Enabled        bool
+	ActiveListings int
+	LastRun        *db.ScrapeRun
+	LastSuccess    *db.ScrapeRun
+}
+
+func (d *Dashboard) index(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	days, err := d.db.NewPropertiesPerDay(ctx, chartDays)
+	if err != nil {
+		d.internalError(w, err)
+		return
+	}
+	maxCount := 0
+	for _, day := range days {
+		maxCount = max(maxCount, day.Count)
+	}
+
+	cfg := d.store.Config()
+	sources := make([]sourceStatus, 0, len(cfg.Sources))
+	for _, s := range cfg.Sources {
+		active, err := d.db.CountActive(ctx, s.Name)
+		if err != nil {
+			d.internalError(w, err)
+			return
+		}
+		last, lastSuccess, err := d.db.LastRuns(ctx, s.Name)
+		if err != nil {
+			d.internalError(w, err)
+			return
+		}
+		sources = append(sources, sourceStatus{
+			Name:           s.Name,
+			Enabled:        !s.Disabled,
+			ActiveListings: active,
+			LastRun:        last,
+			LastSuccess:    lastSuccess,
+		})
+	}
+
+	failures, _, err := d.db.ListRuns(ctx, db.RunFilter{Status: db.RunFailure, Limit: errorCount})
+	if err != nil {
+		d.internalError(w, err)
+		return
+	}
+
+	d.render(w, "index.html", map[string]any{
+		"Days":     days,
+		"MaxCount": maxCount,
+		"Sources":  sources,
+		"Failures": failures,
+	})
+}
+
+func (d *Dashboard) listings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Best scored first, unless the newest are asked for
+	filter := db.PropertyFilter{
+		Source: q.Get("source"),
+		Query:  q.Get("q"),
+		Sort:   "score",
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	}
+	if q.Get("sort") == "newest" {
+		filter.Sort = ""
+	}
+	switch q.Get("status") {
+	case "active":
+		active := true
+		filter.Active = &active
+	case "inactive":
+		active := false
+		filter.Active = &active
+	}
+
+	properties, total, err := d.db.ListProperties(r.Context(), filter)
+	if err != nil {
+		d.internalError(w, err)
+		return
+	}
+
+	// Links to the other pages keep the search
+	pageURL := func(page int) string {
+		values := url.Values{}
+		for key, value := range q {
+			values[key] = value
+		}
+		values.Set("page", strconv.Itoa(page))
+		return "/listings?" + values.Encode()
+	}
+	var prev, next string
+	if page > 1 {
+		prev = pageURL(page - 1)
+	}
+	if page*pageSize < total {
+		next = pageURL(page + 1)
+	}
+
+	cfg := d.store.Config()
+	sources := make([]string, len(cfg.Sources))
+	for i, s := range cfg.Sources {
+		sources[i] = s.Name
+	}
+
+	d.render(w, "listings.html", map[string]any{
+		"Listings": properties,
+		"Total":    total,
+		"Page":     page,
+		"Pages":    (total + pageSize - 1) / pageSize,
+		"Prev":     prev,
+		"Next":     next,
+		"Query":    filter.Query,
+		"Source":   filter.Source,
+		"Status":   q.Get("status"),
+		"Sort":     q.Get("sort"),
+		"Sources":  sources,
+	})
+}
+
+// snapshot serves the screenshot or HTML of a failed scrape.
+func (d *Dashboard) snapshot(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name != filepath.Base(name) || !(strings.HasSuffix(name, ".png") || strings.HasSuffix(name, ".html")) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The HTML comes from another site, don't let it run scripts on ours
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	http.ServeFile(w, r, filepath.Join(d.store.Config().SnapshotDir(), name))
+}
+
+func (d *Dashboard) render(w http.ResponseWriter, page string, data map[string]any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := d.pages[page].ExecuteTemplate(w, "layout", data); err != nil {
+		d.logger.Error("Failed to render page", "page", page, "error", err)
+	}
+}
+
+func (d *Dashboard) internalError(w http.ResponseWriter, err error) {
+	d.logger.Error("Request failed", "error", err)
+	http.Error(w, "Internal error", http.StatusInternalServerError)
+}
@@ -1,27 +1,216 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"huurwoning/address"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var ErrNotFound = errors.New("not found")
+
 type Database struct {
 	db *sql.DB
 }
 
+// Listing is a property as found on a website by a scraper. Address
+// identifies it within its source, the other fields are optional.
+type Listing struct {
+	Address string
+	URL     string
+	Price   int // monthly rent in euros, 0 if unknown
+	Area    int // living area in m², 0 if unknown
+	Rooms   int // 0 if unknown
+	// Date the home can be moved into, zero if unknown
+	AvailableFrom time.Time
+	// From the detail page, nil when it wasn't visited
+	Details *Details
+	// Name of the archived detail page, empty if it wasn't archived
+	Archive string
+}
+
 type Property struct {
 	ID        int64
 	Address   string
 	Source    string // e.g., "rebo", "vesteda", etc.
+	URL       string
+	Price     int
 	FirstSeen time.Time
 	LastSeen  time.Time
 	Active    bool
+	// The first property with the same address, possibly of another source.
+	// 0 when this is the first one.
+	CanonicalID int64
+	// The address split into street, number, postcode and city, as far as
+	// they could be found in Address
+	Parts address.Address
+	// From the imported locations, 0 when the address wasn't found
+	Lat           float64
+	Lon           float64
+	Neighbourhood string
+	Area          int
+	Rooms         int
+	AvailableFrom time.Time
+	// Score between 0 and 1 when the listing was first seen or last rescored,
+	// with the explanation. ScoreDetails is empty when it wasn't scored.
+	Score        float64
+	ScoreDetails string
+}
+
+// HasLocation reports whether the property was geocoded.
+func (p Property) HasLocation() bool {
+	return p.Lat != 0 || p.Lon != 0
+}
+
+// Property returns the listing as an unsaved property of source, with its address parsed.
+func (l Listing) Property(source string) Property {
+	return Property{
+		Address: l.Address, Source: source, URL: l.URL, Price: l.Price, Active: true,
+		Parts: address.Parse(l.Address), Area: l.Area, Rooms: l.Rooms, AvailableFrom: l.AvailableFrom,
+	}
+}
+
+// Canonical returns the id of the property this one is a duplicate of, or its own id.
+func (p Property) Canonical() int64 {
+	if p.CanonicalID != 0 {
+		return p.CanonicalID
+	}
+	return p.ID
+}
+
+// Event types in the history of a property
+const (
+	EventNew          = "new"
+	EventInactive     = "inactive"
+	EventReactivated  = "reactivated"
+	EventPriceChanged = "price_changed"
+)
+
+type Event struct {
+	ID         int64
+	PropertyID int64
+	Type       string
+	Details    string
+	At         time.Time
+}
+
+// migrations are applied in order on top of the original schema, the
+// database's user_version is the number of applied migrations.
+var migrations = []string{
+	`
+        ALTER TABLE properties ADD COLUMN url TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN price INTEGER NOT NULL DEFAULT 0;
+    `,
+	`
+        CREATE TABLE property_events (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            property_id INTEGER NOT NULL REFERENCES properties(id),
+            type TEXT NOT NULL,
+            details TEXT NOT NULL DEFAULT '',
+            at DATETIME NOT NULL
+        );
+        CREATE INDEX property_events_property_id ON property_events(property_id);
+
+        CREATE TABLE scrape_runs (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            source TEXT NOT NULL,
+            started_at DATETIME NOT NULL,
+            finished_at DATETIME,
+            status TEXT NOT NULL,
+            error TEXT NOT NULL DEFAULT '',
+            found INTEGER NOT NULL DEFAULT 0,
+            new INTEGER NOT NULL DEFAULT 0
+        );
+        CREATE INDEX scrape_runs_source ON scrape_runs(source, id);
+    `,
+	`
+        ALTER TABLE scrape_runs ADD COLUMN snapshot TEXT NOT NULL DEFAULT '';
+    `,
+	`
+        ALTER TABLE properties ADD COLUMN address_key TEXT;
+        ALTER TABLE properties ADD COLUMN canonical_id INTEGER REFERENCES properties(id);
+        CREATE INDEX properties_address_key ON properties(address_key);
+    `,
+	`
+        ALTER TABLE properties ADD COLUMN street TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN house_number TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN addition TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN postcode TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN city TEXT NOT NULL DEFAULT '';
+        CREATE INDEX properties_source_address_key ON properties(source, address_key);
+
+        -- Parsed again with the address parser on startup
+        UPDATE properties SET address_key = NULL, canonical_id = NULL;
+    `,
+	`
+        CREATE TABLE locations (
+            postcode TEXT NOT NULL DEFAULT '',
+            house_number TEXT NOT NULL DEFAULT '',
+            street_key TEXT NOT NULL DEFAULT '',
+            city_key TEXT NOT NULL DEFAULT '',
+            lat REAL NOT NULL,
+            lon REAL NOT NULL,
+            neighbourhood TEXT NOT NULL DEFAULT ''
+        );
+        CREATE INDEX locations_postcode ON locations(postcode, house_number);
+        CREATE INDEX locations_street ON locations(street_key, house_number);
+
+        ALTER TABLE properties ADD COLUMN lat REAL;
+        ALTER TABLE properties ADD COLUMN lon REAL;
+        ALTER TABLE properties ADD COLUMN neighbourhood TEXT NOT NULL DEFAULT '';
+    `,
+	`
+        ALTER TABLE properties ADD COLUMN area INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE properties ADD COLUMN rooms INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE properties ADD COLUMN available_from TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN score REAL;
+        ALTER TABLE properties ADD COLUMN score_details TEXT NOT NULL DEFAULT '';
+    `,
+	`
+        ALTER TABLE properties ADD COLUMN description TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN energy_label TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN service_costs INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE properties ADD COLUMN deposit INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE properties ADD COLUMN income_requirement TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN furnishing TEXT NOT NULL DEFAULT '';
+        ALTER TABLE properties ADD COLUMN details_at DATETIME;
+
+        CREATE TABLE property_photos (
+            property_id INTEGER NOT NULL REFERENCES properties(id),
+            position INTEGER NOT NULL,
+            url TEXT NOT NULL,
+            PRIMARY KEY (property_id, position)
+        );
+    `,
+	`
+        ALTER TABLE property_photos ADD COLUMN file TEXT NOT NULL DEFAULT '';
+    `,
+	`
+        ALTER TABLE properties ADD COLUMN archive TEXT NOT NULL DEFAULT '';
+    `,
+	`
+        -- Keys include the postcode or city now, parsed again on startup
+        UPDATE properties SET address_key = NULL, canonical_id = NULL;
+    `,
+	`
+        -- One property per source and key. Keys are parsed again on startup,
+        -- which leaves one property per key and clears the key of the others.
+        UPDATE properties SET address_key = NULL, canonical_id = NULL;
+        DROP INDEX properties_source_address_key;
+        CREATE UNIQUE INDEX properties_source_address_key ON properties(source, address_key) WHERE address_key != '';
+    `,
+	`
+        -- Keys are street|number|addition now, the place is compared when
+        -- linking. Parsed again on startup.
+        UPDATE properties SET address_key = NULL, canonical_id = NULL;
+    `,
 }
 
 func New(dbPath string) (*Database, error) {
@@ -31,7 +220,8 @@ func New(dbPath string) (*Database, error) {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// WAL lets the HTTP API read while a scraper writes
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000&_journal_mode=WAL")
 	if err != nil {
 		return nil, err
 	}
@@ -47,34 +237,256 @@ func New(dbPath string) (*Database, error) {
             active BOOLEAN NOT NULL,
             UNIQUE(address, source)
         );
+
+        CREATE TABLE IF NOT EXISTS health (
+            id INTEGER PRIMARY KEY CHECK (id = 1),
+            checked_at DATETIME NOT NULL
+        );
     `)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := parseAddresses(db); err != nil {
+		return nil, fmt.Errorf("failed to parse addresses: %w", err)
+	}
+
 	return &Database{db: db}, nil
 }
 
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA doesn't take parameters
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-// Add a new property or update existing one
-func (d *Database) UpsertProperty(address, source string) error {
+// Check that the database can be written to
+func (d *Database) CheckWritable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, `
+        INSERT INTO health (id, checked_at) VALUES (1, ?)
+        ON CONFLICT(id) DO UPDATE SET checked_at = excluded.checked_at
+    `, time.Now())
+	return err
+}
+
+// Add a new property or update existing one, recording what changed in its
+// history. Listings are matched on their address key, so a listing whose
+// address is only written differently updates the existing property.
+func (d *Database) UpsertProperty(listing Listing, source string) error {
 	now := time.Now()
+	parts := address.Parse(listing.Address)
+	key := parts.Key()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Addresses without a key are matched on their text
+	var id int64
+	var price int
+	var active bool
+	err = tx.QueryRow(`
+        SELECT id, price, active FROM properties WHERE source = ? AND `+matchAddress+`
+        ORDER BY active DESC, last_seen DESC LIMIT 1
+    `, source, key, key, key, listing.Address).Scan(&id, &price, &active)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		canonical, err := findCanonical(tx, parts)
+		if err != nil {
+			return err
+		}
+		// Another scrape of the source can insert the same key between the
+		// select and here, the listing is then only seen again
+		var inserted bool
+		err = tx.QueryRow(`
+            INSERT INTO properties (address, source, url, price, first_seen, last_seen, active, address_key, canonical_id,
+                                    street, house_number, addition, postcode, city, area, rooms, available_from)
+            VALUES (?, ?, ?, ?, ?, ?, TRUE, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            ON CONFLICT(source, address_key) WHERE address_key != '' DO UPDATE
+            SET last_seen = excluded.last_seen, active = TRUE,
+                url = CASE WHEN excluded.url != '' THEN excluded.url ELSE url END,
+                price = CASE WHEN excluded.price > 0 THEN excluded.price ELSE price END
+            RETURNING id, first_seen = ?
+        `, listing.Address, source, listing.URL, listing.Price, now, now, key, canonical,
+			parts.Street, parts.Number, parts.Addition, parts.Postcode, parts.City,
+			listing.Area, listing.Rooms, formatDate(listing.AvailableFrom), now).Scan(&id, &inserted)
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			break
+		}
+		if err := addEvent(tx, id, EventNew, "", now); err != nil {
+			return err
+		}
+		if _, err := geocode(tx, id, parts); err != nil {
+			return err
+		}
+
+	case err != nil:
+		return err
+
+	default:
+		// Keep what is known when the listing doesn't have it. The place
+		// isn't part of the key, a title that adds or drops it still matches.
+		availableFrom := formatDate(listing.AvailableFrom)
+		_, err := tx.Exec(`
+            UPDATE properties
+            SET last_seen = ?, active = TRUE, address_key = ?, street = ?, house_number = ?, addition = ?,
+                url = CASE WHEN ? != '' THEN ? ELSE url END,
+                price = CASE WHEN ? > 0 THEN ? ELSE price END,
+                postcode = CASE WHEN ? != '' THEN ? ELSE postcode END,
+                city = CASE WHEN ? != '' THEN ? ELSE city END,
+                area = CASE WHEN ? > 0 THEN ? ELSE area END,
+                rooms = CASE WHEN ? > 0 THEN ? ELSE rooms END,
+                available_from = CASE WHEN ? != '' THEN ? ELSE available_from END
+            WHERE id = ?
+        `, now, key, parts.Street, parts.Number, parts.Addition, listing.URL, listing.URL, listing.Price, listing.Price,
+			parts.Postcode, parts.Postcode, parts.City, parts.City,
+			listing.Area, listing.Area, listing.Rooms, listing.Rooms, availableFrom, availableFrom, id)
+		if err != nil {
+			return err
+		}
+		if !active {
+			if err := addEvent(tx, id, EventReactivated, "", now); err != nil {
+				return err
+			}
+		}
+		if listing.Price > 0 && price > 0 && listing.Price != price {
+			details := fmt.Sprintf("%d -> %d", price, listing.Price)
+			if err := addEvent(tx, id, EventPriceChanged, details, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	if listing.Details != nil {
+		if err := setDetails(tx, id, *listing.Details, now); err != nil {
+			return err
+		}
+	}
+	if listing.Archive != "" {
+		if _, err := tx.Exec(`UPDATE properties SET archive = ? WHERE id = ?`, listing.Archive, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func addEvent(tx *sql.Tx, propertyID int64, eventType, details string, at time.Time) error {
+	_, err := tx.Exec(`
+        INSERT INTO property_events (property_id, type, details, at) VALUES (?, ?, ?, ?)
+    `, propertyID, eventType, details, at)
+	return err
+}
+
+const propertyColumns = `id, address, source, url, price, first_seen, last_seen, active, COALESCE(canonical_id, 0),
+    street, house_number, addition, postcode, city, COALESCE(lat, 0), COALESCE(lon, 0), neighbourhood,
+    area, rooms, available_from, COALESCE(score, 0), score_details`
+
+// propertyFields returns where to scan propertyColumns into.
+func propertyFields(p *Property) []any {
+	return []any{&p.ID, &p.Address, &p.Source, &p.URL, &p.Price, &p.FirstSeen, &p.LastSeen, &p.Active, &p.CanonicalID,
+		&p.Parts.Street, &p.Parts.Number, &p.Parts.Addition, &p.Parts.Postcode, &p.Parts.City,
+		&p.Lat, &p.Lon, &p.Neighbourhood,
+		&p.Area, &p.Rooms, dateColumn{&p.AvailableFrom}, &p.Score, &p.ScoreDetails}
+}
+
+const dateLayout = "2006-01-02"
+
+// formatDate stores a date without time, empty when it is unknown.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateLayout)
+}
+
+// dateColumn scans a column written by formatDate.
+type dateColumn struct {
+	t *time.Time
+}
+
+func (d dateColumn) Scan(value any) error {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("unexpected date %T", value)
+	}
+	if text == "" {
+		*d.t = time.Time{}
+		return nil
+	}
+	t, err := time.ParseInLocation(dateLayout, text, time.Local)
+	if err != nil {
+		return err
+	}
+	*d.t = t
+	return nil
+}
+
+// matchAddress is a condition for the properties with an address, taking
+// the address key twice, then the address key and the address. Addresses
+// without a key only match the same text without a key.
+const matchAddress = `(? != '' AND address_key = ? OR ? = '' AND address_key = '' AND address = ?)`
+
+// SetScore stores the score of a property of source.
+func (d *Database) SetScore(source, addr string, score float64, details string) error {
+	key := address.Key(addr)
 	_, err := d.db.Exec(`
-        INSERT INTO properties (address, source, first_seen, last_seen, active)
-        VALUES (?, ?, ?, ?, TRUE)
-        ON CONFLICT(address, source)
-        DO UPDATE SET last_seen = ?, active = TRUE
-    `, address, source, now, now, now)
+        UPDATE properties SET score = ?, score_details = ?
+        WHERE source = ? AND active = TRUE AND `+matchAddress+`
+    `, score, details, source, key, key, key, addr)
 	return err
 }
 
+func scanProperty(row interface{ Scan(...any) error }) (Property, error) {
+	var p Property
+	err := row.Scan(propertyFields(&p)...)
+	return p, err
+}
+
 // Get all active properties for a source
 func (d *Database) GetActiveProperties(source string) ([]Property, error) {
 	rows, err := d.db.Query(`
-        SELECT id, address, source, first_seen, last_seen, active
+        SELECT `+propertyColumns+`
         FROM properties
         WHERE source = ? AND active = TRUE
     `, source)
@@ -85,34 +497,61 @@ func (d *Database) GetActiveProperties(source string) ([]Property, error) {
 
 	var properties []Property
 	for rows.Next() {
-		var p Property
-		err := rows.Scan(&p.ID, &p.Address, &p.Source, &p.FirstSeen, &p.LastSeen, &p.Active)
+		p, err := scanProperty(rows)
 		if err != nil {
 			return nil, err
 		}
 		properties = append(properties, p)
 	}
-	return properties, nil
+	return properties, rows.Err()
 }
 
-// Mark properties as inactive if they're no longer listed
+// Mark properties as inactive if they're no longer listed, compared by address key
 func (d *Database) MarkInactive(source string, activeAddresses []string) error {
 	if len(activeAddresses) == 0 {
 		return nil
 	}
-	query := `
-        UPDATE properties
-        SET active = FALSE
-        WHERE source = ?
-        AND active = TRUE
-        AND address NOT IN (?` + strings.Repeat(",?", len(activeAddresses)-1) + ")"
+	listed := make(map[string]bool, len(activeAddresses))
+	for _, addr := range activeAddresses {
+		listed[address.SourceKey(addr)] = true
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, address FROM properties WHERE source = ? AND active = TRUE`, source)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var addr string
+		if err := rows.Scan(&id, &addr); err != nil {
+			rows.Close()
+			return err
+		}
+		if !listed[address.SourceKey(addr)] {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-	args := make([]interface{}, len(activeAddresses)+1)
-	args[0] = source
-	for i, addr := range activeAddresses {
-		args[i+1] = addr
+	now := time.Now()
+	for _, id := range ids {
+		if _, err := tx.Exec(`UPDATE properties SET active = FALSE WHERE id = ?`, id); err != nil {
+			return err
+		}
+		if err := addEvent(tx, id, EventInactive, "", now); err != nil {
+			return err
+		}
 	}
 
-	_, err := d.db.Exec(query, args...)
-	return err
+	return tx.Commit()
 }
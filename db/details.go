@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Furnishing of a home, from the detail page
+const (
+	Furnished   = "furnished"
+	Upholstered = "upholstered"
+	Bare        = "bare"
+)
+
+// Details are read from the detail page of a listing. Zero values weren't found.
+type Details struct {
+	Description  string
+	EnergyLabel  string
+	ServiceCosts int // euros per month
+	Deposit      int // euros
+	// As written on the page, e.g. "3x de kale huur"
+	IncomeRequirement string
+	// Furnished, Upholstered or Bare
+	Furnishing string
+	// In page order
+	Photos []Photo
+	// When the detail page was visited, zero if it wasn't
+	At time.Time
+}
+
+// Photo is a photo on the detail page of a listing.
+type Photo struct {
+	URL string
+	// Name of the downloaded photo in the photo store, empty if it wasn't downloaded
+	File string
+}
+
+func setDetails(tx *sql.Tx, id int64, d Details, at time.Time) error {
+	_, err := tx.Exec(`
+        UPDATE properties
+        SET description = ?, energy_label = ?, service_costs = ?, deposit = ?,
+            income_requirement = ?, furnishing = ?, details_at = ?
+        WHERE id = ?
+    `, d.Description, d.EnergyLabel, d.ServiceCosts, d.Deposit, d.IncomeRequirement, d.Furnishing, at, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM property_photos WHERE property_id = ?`, id); err != nil {
+		return err
+	}
+	for i, photo := range d.Photos {
+		_, err := tx.Exec(`
+            INSERT INTO property_photos (property_id, position, url, file) VALUES (?, ?, ?, ?)
+        `, id, i, photo.URL, photo.File)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDetails returns the details of a property, ErrNotFound if it doesn't exist.
+func (d *Database) GetDetails(ctx context.Context, id int64) (*Details, error) {
+	var details Details
+	var at sql.NullTime
+	err := d.db.QueryRowContext(ctx, `
+        SELECT description, energy_label, service_costs, deposit, income_requirement, furnishing, details_at
+        FROM properties WHERE id = ?
+    `, id).Scan(&details.Description, &details.EnergyLabel, &details.ServiceCosts, &details.Deposit,
+		&details.IncomeRequirement, &details.Furnishing, &at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	details.At = at.Time
+
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT url, file FROM property_photos WHERE property_id = ? ORDER BY position
+    `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var photo Photo
+		if err := rows.Scan(&photo.URL, &photo.File); err != nil {
+			return nil, err
+		}
+		details.Photos = append(details.Photos, photo)
+	}
+	return &details, rows.Err()
+}
+
+// GetArchive returns the name of the archived detail page of a property,
+// empty if there is none, ErrNotFound if the property doesn't exist.
+func (d *Database) GetArchive(ctx context.Context, id int64) (string, error) {
+	var name string
+	err := d.db.QueryRowContext(ctx, `SELECT archive FROM properties WHERE id = ?`, id).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return name, err
+}
+
+// ClearArchives forgets the archived detail pages with the given names, after
+// they were removed.
+func (d *Database) ClearArchives(names []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, name := range names {
+		if _, err := tx.Exec(`UPDATE properties SET archive = '' WHERE archive = ?`, name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
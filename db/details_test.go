@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClearArchives(t *testing.T) {
+	d := newTestDatabase(t)
+	listings := []Listing{
+		{Address: "Oudegracht 12, Utrecht", Archive: "REBO/20260101-120000-3fa2b9c1"},
+		{Address: "Kerkstraat 1, Amersfoort", Archive: "REBO/20261001-120000-0badf00d"},
+	}
+	for _, l := range listings {
+		if err := d.UpsertProperty(l, "REBO"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.ClearArchives([]string{"REBO/20260101-120000-3fa2b9c1"}); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[int64]string{1: "", 2: "REBO/20261001-120000-0badf00d"} {
+		got, err := d.GetArchive(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("archive of %d = %q, want %q", id, got, want)
+		}
+	}
+}
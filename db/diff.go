@@ -0,0 +1,86 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+
+	"huurwoning/address"
+)
+
+// Change is a change to a property that storing a scrape would make.
+type Change struct {
+	Type    string // one of the event types
+	Address string
+	Details string
+}
+
+// Diff returns the changes that UpsertProperty for every listing and
+// MarkInactive would make for source, without writing anything.
+func (d *Database) Diff(source string, listings []Listing) ([]Change, error) {
+	rows, err := d.db.Query(`
+        SELECT `+propertyColumns+` FROM properties WHERE source = ?
+    `, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[string]Property)
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			return nil, err
+		}
+		// Prefer the active one of properties stored before addresses were parsed
+		key := address.SourceKey(p.Address)
+		if k, ok := known[key]; !ok || p.Active && !k.Active {
+			known[key] = p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	found := make(map[string]bool)
+	for _, listing := range listings {
+		key := address.SourceKey(listing.Address)
+		if found[key] {
+			continue
+		}
+		found[key] = true
+
+		p, ok := known[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Type: EventNew, Address: listing.Address})
+			continue
+		case !p.Active:
+			changes = append(changes, Change{Type: EventReactivated, Address: listing.Address})
+		}
+		if listing.Price > 0 && p.Price > 0 && listing.Price != p.Price {
+			changes = append(changes, Change{
+				Type:    EventPriceChanged,
+				Address: listing.Address,
+				Details: fmt.Sprintf("%d -> %d", p.Price, listing.Price),
+			})
+		}
+	}
+
+	// Like MarkInactive, nothing is marked inactive without listings
+	if len(listings) == 0 {
+		return changes, nil
+	}
+	var gone []string
+	for key, p := range known {
+		if p.Active && !found[key] {
+			gone = append(gone, p.Address)
+		}
+	}
+	slices.Sort(gone)
+	for _, a := range gone {
+		changes = append(changes, Change{Type: EventInactive, Address: a})
+	}
+
+	return changes, nil
+}
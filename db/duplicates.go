@@ -0,0 +1,245 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"huurwoning/address"
+)
+
+// Properties with the same address key in compatible places are the same
+// home, often listed by several sources. The first one seen is the canonical
+// property, later ones point to it with canonical_id. Only keys with a house
+// number are linked, see address.Specific, a street alone is too vague.
+
+// samePlace reports whether addresses with the same key can be the same home:
+// see address.SamePlace, or the postcode of one is in the city of the other
+// according to the imported locations.
+func samePlace(q queryer, a, b address.Address) (bool, error) {
+	if address.SamePlace(a, b) {
+		return true, nil
+	}
+	for _, pair := range [][2]address.Address{{a, b}, {b, a}} {
+		postcode, city := pair[0].Postcode, address.NormalizeStreet(pair[1].City)
+		if postcode == "" || city == "" {
+			continue
+		}
+		var in bool
+		err := q.QueryRow(`
+            SELECT EXISTS (SELECT 1 FROM locations WHERE postcode = ? AND city_key = ?)
+        `, postcode, city).Scan(&in)
+		if err != nil || in {
+			return in, err
+		}
+	}
+	return false, nil
+}
+
+// findCanonical returns the id of the canonical property with the address of
+// parts, or nil if there is none.
+func findCanonical(tx *sql.Tx, parts address.Address) (any, error) {
+	key := parts.Key()
+	if !address.Specific(key) {
+		return nil, nil
+	}
+	rows, err := tx.Query(`
+        SELECT id, postcode, city FROM properties WHERE address_key = ? AND canonical_id IS NULL
+        ORDER BY first_seen, id
+    `, key)
+	if err != nil {
+		return nil, err
+	}
+	type candidate struct {
+		id    int64
+		parts address.Address
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.parts.Postcode, &c.parts.City); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		same, err := samePlace(tx, parts, c.parts)
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			return c.id, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseAddresses parses the addresses of properties stored before they were
+// parsed, and links them to their canonical property. A source has one
+// property per key: when several have the same key, the active and last seen
+// one keeps it and the others are deactivated without a key.
+func parseAddresses(db *sql.DB) error {
+	rows, err := db.Query(`
+        SELECT id, address, source, active, last_seen FROM properties
+        WHERE address_key IS NULL ORDER BY first_seen, id
+    `)
+	if err != nil {
+		return err
+	}
+	type unparsed struct {
+		id       int64
+		address  string
+		source   string
+		active   bool
+		lastSeen time.Time
+	}
+	var properties []unparsed
+	for rows.Next() {
+		var p unparsed
+		if err := rows.Scan(&p.id, &p.address, &p.source, &p.active, &p.lastSeen); err != nil {
+			rows.Close()
+			return err
+		}
+		properties = append(properties, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(properties) == 0 {
+		return err
+	}
+
+	// The property that keeps a key of a source
+	keeps := make(map[[2]string]unparsed)
+	for _, p := range properties {
+		key := [2]string{p.source, address.Key(p.address)}
+		if key[1] == "" {
+			continue
+		}
+		k, ok := keeps[key]
+		if !ok || p.active && !k.active || p.active == k.active && !p.lastSeen.Before(k.lastSeen) {
+			keeps[key] = p
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Oldest first, so the canonical property is linked before its duplicates
+	now := time.Now()
+	for _, p := range properties {
+		parts := address.Parse(p.address)
+		key := parts.Key()
+		taken, err := keyTaken(tx, p.source, key, p.id)
+		if err != nil {
+			return err
+		}
+		if key != "" && (keeps[[2]string{p.source, key}].id != p.id || taken) {
+			key = ""
+			if p.active {
+				if _, err := tx.Exec(`UPDATE properties SET active = FALSE WHERE id = ?`, p.id); err != nil {
+					return err
+				}
+				if err := addEvent(tx, p.id, EventInactive, "same address as another listing", now); err != nil {
+					return err
+				}
+			}
+		}
+		var canonical any
+		if key != "" {
+			if canonical, err = findCanonical(tx, parts); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(`
+            UPDATE properties
+            SET address_key = ?, canonical_id = ?, street = ?, house_number = ?, addition = ?, postcode = ?, city = ?
+            WHERE id = ?
+        `, key, canonical, parts.Street, parts.Number, parts.Addition, parts.Postcode, parts.City, p.id)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// keyTaken reports whether another property of source already has key.
+func keyTaken(tx *sql.Tx, source, key string, id int64) (bool, error) {
+	var taken bool
+	err := tx.QueryRow(`
+        SELECT EXISTS (SELECT 1 FROM properties WHERE source = ? AND address_key = ? AND address_key != '' AND id != ?)
+    `, source, key, id).Scan(&taken)
+	return taken, err
+}
+
+// ListedElsewhere returns an active property of another source with the same
+// address as listing, or nil if there is none.
+func (d *Database) ListedElsewhere(listing Listing, source string) (*Property, error) {
+	parts := address.Parse(listing.Address)
+	key := parts.Key()
+	if !address.Specific(key) {
+		return nil, nil
+	}
+	rows, err := d.db.Query(`
+        SELECT `+propertyColumns+` FROM properties
+        WHERE address_key = ? AND source != ? AND active = TRUE
+        ORDER BY first_seen, id
+    `, key, source)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []Property
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range candidates {
+		same, err := samePlace(d.db, parts, p.Parts)
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+// Duplicates returns the other properties with the same canonical property as p.
+func (d *Database) Duplicates(ctx context.Context, p Property) ([]Property, error) {
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT `+propertyColumns+` FROM properties
+        WHERE COALESCE(canonical_id, id) = ? AND id != ?
+        ORDER BY first_seen, id
+    `, p.Canonical(), p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := []Property{}
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, p)
+	}
+	return properties, rows.Err()
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	d, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.db.Close() })
+	return d
+}
+
+func TestUpsertPropertyMatchesOnKey(t *testing.T) {
+	d := newTestDatabase(t)
+	listings := []string{
+		"Kerkstraat 1, Amersfoort",
+		"kerkstraat 1, UTRECHT",
+		"Kerkstraat 1-A, Utrecht",
+		"3511 AB Utrecht",
+		"3811 CV Amersfoort",
+	}
+	for _, a := range listings {
+		if err := d.UpsertProperty(Listing{Address: a}, "REBO"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	properties, err := d.GetActiveProperties("REBO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(properties) != 4 {
+		t.Errorf("got %d properties, want 4: two additions and two postcodes", len(properties))
+	}
+}
+
+func TestListedElsewhere(t *testing.T) {
+	d := newTestDatabase(t)
+	for _, a := range []string{"Kerkstraat 1, Amersfoort", "Biltstraat 5", "Oudegracht 12, 3511 AB"} {
+		if err := d.UpsertProperty(Listing{Address: a}, "REBO"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"Kerkstraat 1, Amersfoort", true},
+		{"Kerkstraat 1, Utrecht", false},
+		{"Kerkstraat 1", true},
+		{"Oudegracht 12, 3511AB Utrecht", true},
+		{"Oudegracht 12, 3512 JE", false},
+		// The postcode isn't known to be in Utrecht without the locations
+		{"Oudegracht 12, Utrecht", false},
+		{"Biltstraat 5", true},
+		{"Biltstraat 5, Utrecht", true},
+		{"Biltstraat 7, Utrecht", false},
+		// Without a house number the street is too vague
+		{"Biltstraat", false},
+		{"3511 AB Utrecht", false},
+	}
+	for _, tt := range tests {
+		p, err := d.ListedElsewhere(Listing{Address: tt.address}, "VESTEDA")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p != nil; got != tt.want {
+			t.Errorf("ListedElsewhere(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestLinkPostcodeToCity(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+		linked bool
+	}{
+		{name: "postcode, then city", first: "Straat 12-A, 3511 AB", second: "Straat 12a, Utrecht", linked: true},
+		{name: "city, then postcode", first: "Straat 12-A, Utrecht", second: "straat 12 a, 3511AB", linked: true},
+		{name: "postcode in another city", first: "Straat 12-A, 3511 AB", second: "Straat 12a, Amersfoort", linked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDatabase(t)
+			_, err := d.ImportLocations(false, locations(io.EOF,
+				Location{Postcode: "3511AB", HouseNumber: "12", Street: "Straat", City: "Utrecht", Lat: 52.09, Lon: 5.12},
+			))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.UpsertProperty(Listing{Address: tt.first}, "REBO"); err != nil {
+				t.Fatal(err)
+			}
+			elsewhere, err := d.ListedElsewhere(Listing{Address: tt.second}, "VESTEDA")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := elsewhere != nil; got != tt.linked {
+				t.Errorf("ListedElsewhere(%q) = %v, want %v", tt.second, got, tt.linked)
+			}
+
+			if err := d.UpsertProperty(Listing{Address: tt.second}, "VESTEDA"); err != nil {
+				t.Fatal(err)
+			}
+			var first, canonical int64
+			err = d.db.QueryRow(`
+                SELECT (SELECT id FROM properties WHERE source = 'REBO'),
+                       (SELECT COALESCE(canonical_id, 0) FROM properties WHERE source = 'VESTEDA')
+            `).Scan(&first, &canonical)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := canonical == first; got != tt.linked {
+				t.Errorf("linked = %v, want %v", got, tt.linked)
+			}
+		})
+	}
+}
+
+func TestParseAddressesKeepsOnePropertyPerKey(t *testing.T) {
+	d := newTestDatabase(t)
+	now := time.Now()
+	rows := []struct {
+		address  string
+		active   bool
+		lastSeen time.Time
+	}{
+		{"Straat 12-A, Utrecht", true, now.Add(-time.Hour)},
+		{"straat 12a, utrecht", true, now},
+		{"Straat 12 a, Utrecht", false, now.Add(time.Hour)},
+	}
+	for _, r := range rows {
+		_, err := d.db.Exec(`
+            INSERT INTO properties (address, source, first_seen, last_seen, active) VALUES (?, 'REBO', ?, ?, ?)
+        `, r.address, now, r.lastSeen, r.active)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := parseAddresses(d.db); err != nil {
+		t.Fatal(err)
+	}
+
+	var keyed, active int
+	var kept string
+	err := d.db.QueryRow(`
+        SELECT COUNT(*), SUM(active), MAX(address) FROM properties WHERE address_key != ''
+    `).Scan(&keyed, &active, &kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyed != 1 || active != 1 || kept != "straat 12a, utrecht" {
+		t.Errorf("got %d keyed and %d active, kept %q; want the active, last seen one", keyed, active, kept)
+	}
+
+	// A listing with the key updates the kept property
+	if err := d.UpsertProperty(Listing{Address: "STRAAT 12-a, Utrecht"}, "REBO"); err != nil {
+		t.Fatal(err)
+	}
+	properties, err := d.GetActiveProperties("REBO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(properties) != 1 {
+		t.Errorf("got %d active properties, want 1", len(properties))
+	}
+}
+
+func TestUniqueKeyPerSource(t *testing.T) {
+	d := newTestDatabase(t)
+	if err := d.UpsertProperty(Listing{Address: "Kerkstraat 1, Utrecht"}, "REBO"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.db.Exec(`
+        INSERT INTO properties (address, source, first_seen, last_seen, active, address_key)
+        VALUES ('Kerkstraat 1 Utrecht', 'REBO', ?, ?, TRUE, 'kerkstraat|1|')
+    `, time.Now(), time.Now())
+	if err == nil {
+		t.Error("inserted a second property with the same source and key")
+	}
+}
+
+func TestUpsertPropertyPlaceChanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		titles       []string
+		wantPostcode string
+		wantCity     string
+	}{
+		{name: "gains a postcode", titles: []string{"Oudegracht 12", "Oudegracht 12, 3511 AB Utrecht"}, wantPostcode: "3511AB", wantCity: "Utrecht"},
+		{name: "drops the city", titles: []string{"Kerkstraat 1, Utrecht", "Kerkstraat 1"}, wantCity: "Utrecht"},
+		{name: "city instead of postcode", titles: []string{"Straat 12-A, 3511 AB", "straat 12a, Utrecht"}, wantPostcode: "3511AB", wantCity: "Utrecht"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDatabase(t)
+			for _, title := range tt.titles {
+				if err := d.UpsertProperty(Listing{Address: title}, "REBO"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var properties, newEvents int
+			var postcode, city string
+			err := d.db.QueryRow(`
+                SELECT COUNT(*), MAX(postcode), MAX(city),
+                       (SELECT COUNT(*) FROM property_events WHERE type = ?)
+                FROM properties
+            `, EventNew).Scan(&properties, &postcode, &city, &newEvents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if properties != 1 || newEvents != 1 {
+				t.Errorf("got %d properties and %d new events, want 1 of each", properties, newEvents)
+			}
+			if postcode != tt.wantPostcode || city != tt.wantCity {
+				t.Errorf("place = %q %q, want %q %q", postcode, city, tt.wantPostcode, tt.wantCity)
+			}
+		})
+	}
+}
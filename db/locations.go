@@ -0,0 +1,195 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"math"
+
+	"huurwoning/address"
+)
+
+// Location is a geocoded address from an imported postcode dataset.
+type Location struct {
+	Postcode      string // 1234AB
+	HouseNumber   string
+	Street        string
+	City          string
+	Lat           float64
+	Lon           float64
+	Neighbourhood string
+}
+
+// Rows are committed in batches, a full address dataset has millions
+const importBatch = 50000
+
+// ImportLocations stores the locations returned by next until it returns
+// io.EOF, replacing the existing ones when replace is set. A replacement is
+// one transaction, so the old locations are kept when it fails. Properties
+// without a location are geocoded afterwards. It returns the number of
+// imported locations.
+func (d *Database) ImportLocations(replace bool, next func() (Location, error)) (int, error) {
+	batch := importBatch
+	if replace {
+		batch = math.MaxInt
+	}
+
+	count := 0
+	for done := false; !done; {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return count, err
+		}
+		if replace && count == 0 {
+			if _, err := tx.Exec(`DELETE FROM locations`); err != nil {
+				tx.Rollback()
+				return count, err
+			}
+		}
+		stmt, err := tx.Prepare(`
+            INSERT INTO locations (postcode, house_number, street_key, city_key, lat, lon, neighbourhood)
+            VALUES (?, ?, ?, ?, ?, ?, ?)
+        `)
+		if err != nil {
+			tx.Rollback()
+			return count, err
+		}
+
+		for i := 0; i < batch; i++ {
+			l, err := next()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err == nil {
+				_, err = stmt.Exec(l.Postcode, l.HouseNumber, address.NormalizeStreet(l.Street),
+					address.NormalizeStreet(l.City), l.Lat, l.Lon, l.Neighbourhood)
+			}
+			if err != nil {
+				stmt.Close()
+				tx.Rollback()
+				return count, err
+			}
+			count++
+		}
+
+		stmt.Close()
+		if err := tx.Commit(); err != nil {
+			return count, err
+		}
+	}
+
+	_, err := d.GeocodeProperties()
+	return count, err
+}
+
+// GeocodeProperties looks up the location of properties that don't have
+// one yet, and returns how many were found.
+func (d *Database) GeocodeProperties() (int, error) {
+	rows, err := d.db.Query(`SELECT ` + propertyColumns + ` FROM properties WHERE lat IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	var properties []Property
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		properties = append(properties, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	found := 0
+	for _, p := range properties {
+		ok, err := geocode(tx, p.ID, p.Parts)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			found++
+		}
+	}
+	return found, tx.Commit()
+}
+
+// LookupLocation returns the location of an address, and whether it was found.
+func (d *Database) LookupLocation(parts address.Address) (Location, bool, error) {
+	return lookupLocation(d.db, parts)
+}
+
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// lookupLocation finds an address by postcode and house number, then by
+// street and house number, and falls back to the middle of the postcode.
+func lookupLocation(q queryer, parts address.Address) (Location, bool, error) {
+	var l Location
+	scan := func(query string, args ...any) (bool, error) {
+		// Aggregates return NULL instead of no rows
+		var lat, lon sql.NullFloat64
+		var neighbourhood sql.NullString
+		err := q.QueryRow(query, args...).Scan(&lat, &lon, &neighbourhood)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && !lat.Valid {
+			return false, nil
+		}
+		l.Lat, l.Lon, l.Neighbourhood = lat.Float64, lon.Float64, neighbourhood.String
+		return err == nil, err
+	}
+
+	if parts.Postcode != "" && parts.Number != "" {
+		ok, err := scan(`
+            SELECT lat, lon, neighbourhood FROM locations WHERE postcode = ? AND house_number = ? LIMIT 1
+        `, parts.Postcode, parts.Number)
+		if ok || err != nil {
+			return l, ok, err
+		}
+	}
+
+	if parts.Street != "" && parts.Number != "" {
+		// Without a city only streets whose name is unique in the dataset are used
+		city := address.NormalizeStreet(parts.City)
+		ok, err := scan(`
+            SELECT MIN(lat), MIN(lon), MIN(neighbourhood) FROM locations
+            WHERE street_key = ? AND house_number = ? AND (? = '' OR city_key = ?)
+            HAVING COUNT(DISTINCT city_key) = 1
+        `, address.NormalizeStreet(parts.Street), parts.Number, city, city)
+		if ok || err != nil {
+			return l, ok, err
+		}
+	}
+
+	if parts.Postcode != "" {
+		ok, err := scan(`
+            SELECT AVG(lat), AVG(lon), MIN(neighbourhood) FROM locations WHERE postcode = ?
+        `, parts.Postcode)
+		if ok || err != nil {
+			return l, ok, err
+		}
+	}
+
+	return l, false, nil
+}
+
+// geocode stores the location of property id, and reports whether it was found.
+func geocode(tx *sql.Tx, id int64, parts address.Address) (bool, error) {
+	l, ok, err := lookupLocation(tx, parts)
+	if err != nil || !ok {
+		return false, err
+	}
+	_, err = tx.Exec(`
+        UPDATE properties SET lat = ?, lon = ?, neighbourhood = ? WHERE id = ?
+    `, l.Lat, l.Lon, l.Neighbourhood, id)
+	return err == nil, err
+}
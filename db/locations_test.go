@@ -0,0 +1,60 @@
+package db
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// locations returns a next func for ImportLocations that returns ls, then err.
+func locations(err error, ls ...Location) func() (Location, error) {
+	return func() (Location, error) {
+		if len(ls) == 0 {
+			return Location{}, err
+		}
+		l := ls[0]
+		ls = ls[1:]
+		return l, nil
+	}
+}
+
+func countLocations(t *testing.T, d *Database) int {
+	t.Helper()
+	var n int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM locations`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestImportLocationsReplace(t *testing.T) {
+	oudegracht := Location{Postcode: "3511AB", HouseNumber: "12", Street: "Oudegracht", City: "Utrecht", Lat: 52.09, Lon: 5.12}
+	biltstraat := Location{Postcode: "3572AA", HouseNumber: "5", Street: "Biltstraat", City: "Utrecht", Lat: 52.1, Lon: 5.13}
+	failure := errors.New("malformed row")
+
+	tests := []struct {
+		name    string
+		next    func() (Location, error)
+		wantErr bool
+		want    int
+	}{
+		{name: "success", next: locations(io.EOF, biltstraat), want: 1},
+		{name: "failure keeps the old locations", next: locations(failure, biltstraat), wantErr: true, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDatabase(t)
+			if _, err := d.ImportLocations(false, locations(io.EOF, oudegracht, biltstraat)); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := d.ImportLocations(true, tt.next)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ImportLocations() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := countLocations(t, d); got != tt.want {
+				t.Errorf("%d locations, want %d", got, tt.want)
+			}
+		})
+	}
+}
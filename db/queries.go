@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// PropertyFilter selects properties in ListProperties. Zero values don't filter.
+type PropertyFilter struct {
+	Source   string
+	Active   *bool
+	Since    time.Time // first seen at or after
+	Until    time.Time // first seen before
+	MinPrice int
+	MaxPrice int
+	Query    string // part of the address
+	// Only properties that aren't a duplicate of one listed earlier
+	Canonical bool
+	// "score" for the highest score first, newest first otherwise
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// List properties matching the filter, newest or best scored first, with the total number of matches
+func (d *Database) ListProperties(ctx context.Context, f PropertyFilter) ([]Property, int, error) {
+	var conditions []string
+	var args []any
+	if f.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, f.Source)
+	}
+	if f.Active != nil {
+		conditions = append(conditions, "active = ?")
+		args = append(args, *f.Active)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "first_seen >= ?")
+		args = append(args, f.Since)
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "first_seen < ?")
+		args = append(args, f.Until)
+	}
+	if f.MinPrice > 0 {
+		conditions = append(conditions, "price >= ?")
+		args = append(args, f.MinPrice)
+	}
+	if f.MaxPrice > 0 {
+		conditions = append(conditions, "price > 0 AND price <= ?")
+		args = append(args, f.MaxPrice)
+	}
+	if f.Query != "" {
+		conditions = append(conditions, "address LIKE ?")
+		args = append(args, "%"+f.Query+"%")
+	}
+	if f.Canonical {
+		conditions = append(conditions, "canonical_id IS NULL")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM properties `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := "first_seen DESC, id DESC"
+	if f.Sort == "score" {
+		order = "score IS NULL, score DESC, " + order
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT `+propertyColumns+` FROM properties `+where+`
+        ORDER BY `+order+` LIMIT ? OFFSET ?
+    `, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	properties := []Property{}
+	for rows.Next() {
+		p, err := scanProperty(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		properties = append(properties, p)
+	}
+	return properties, total, rows.Err()
+}
+
+// Get a property by id, returns ErrNotFound if it doesn't exist
+func (d *Database) GetProperty(ctx context.Context, id int64) (*Property, error) {
+	p, err := scanProperty(d.db.QueryRowContext(ctx, `
+        SELECT `+propertyColumns+` FROM properties WHERE id = ?
+    `, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Get the history of a property, oldest first
+func (d *Database) PropertyEvents(ctx context.Context, propertyID int64) ([]Event, error) {
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT id, property_id, type, details, at FROM property_events
+        WHERE property_id = ? ORDER BY at, id
+    `, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.PropertyID, &e.Type, &e.Details, &e.At); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Count the active properties of a source
+func (d *Database) CountActive(ctx context.Context, source string) (int, error) {
+	var count int
+	err := d.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM properties WHERE source = ? AND active = TRUE
+    `, source).Scan(&count)
+	return count, err
+}
+
+type DayCount struct {
+	Day   string // 2006-01-02, local time
+	Count int
+}
+
+// Count the properties first seen per day over the last days, oldest first,
+// including days without any
+func (d *Database) NewPropertiesPerDay(ctx context.Context, days int) ([]DayCount, error) {
+	start := time.Now().AddDate(0, 0, -days+1)
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+
+	// Times are stored as local time text, so the date is its first 10 characters
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT substr(first_seen, 1, 10) AS day, COUNT(*)
+        FROM properties WHERE first_seen >= ?
+        GROUP BY day
+    `, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var day string
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+		counts[day] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]DayCount, days)
+	for i := range result {
+		day := start.AddDate(0, 0, i).Format(time.DateOnly)
+		result[i] = DayCount{Day: day, Count: counts[day]}
+	}
+	return result, nil
+}
+
+// EventFilter selects events in ListEvents. Zero values don't filter.
+type EventFilter struct {
+	Source string
+	Since  time.Time // at or after
+	Until  time.Time // before
+}
+
+// PropertyEvent is an event together with the property it belongs to.
+type PropertyEvent struct {
+	Event
+	Property Property
+}
+
+// List the events of all properties matching the filter, oldest first
+func (d *Database) ListEvents(ctx context.Context, f EventFilter) ([]PropertyEvent, error) {
+	var conditions []string
+	var args []any
+	if f.Source != "" {
+		conditions = append(conditions, "p.source = ?")
+		args = append(args, f.Source)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "e.at >= ?")
+		args = append(args, f.Since)
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "e.at < ?")
+		args = append(args, f.Until)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT e.id, e.property_id, e.type, e.details, e.at, p.*
+        FROM property_events e JOIN (SELECT `+propertyColumns+` FROM properties) p ON p.id = e.property_id
+        `+where+`
+        ORDER BY e.at, e.id
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []PropertyEvent{}
+	for rows.Next() {
+		var e PropertyEvent
+		fields := append([]any{&e.ID, &e.PropertyID, &e.Type, &e.Details, &e.At}, propertyFields(&e.Property)...)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
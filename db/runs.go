@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Scrape run statuses
+const (
+	RunRunning = "running"
+	RunSuccess = "success"
+	RunFailure = "failure"
+)
+
+type ScrapeRun struct {
+	ID         int64
+	Source     string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	Status     string
+	Error      string
+	Found      int
+	New        int
+	Snapshot   string // name of the snapshot taken when the run failed
+}
+
+// Start a scrape run for a source, returns the id to finish it with
+func (d *Database) StartRun(source string) (int64, error) {
+	res, err := d.db.Exec(`
+        INSERT INTO scrape_runs (source, started_at, status) VALUES (?, ?, ?)
+    `, source, time.Now(), RunRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Set the number of found and new listings of a scrape run
+func (d *Database) SetRunCounts(id int64, found, new int) error {
+	_, err := d.db.Exec(`
+        UPDATE scrape_runs SET found = ?, new = ? WHERE id = ?
+    `, found, new, id)
+	return err
+}
+
+// Link a snapshot to a scrape run
+func (d *Database) SetRunSnapshot(id int64, snapshot string) error {
+	_, err := d.db.Exec(`
+        UPDATE scrape_runs SET snapshot = ? WHERE id = ?
+    `, snapshot, id)
+	return err
+}
+
+// Finish a scrape run, runErr is the error the run failed with or nil
+func (d *Database) FinishRun(id int64, runErr error) error {
+	status, message := RunSuccess, ""
+	if runErr != nil {
+		status, message = RunFailure, runErr.Error()
+	}
+	_, err := d.db.Exec(`
+        UPDATE scrape_runs SET finished_at = ?, status = ?, error = ? WHERE id = ?
+    `, time.Now(), status, message, id)
+	return err
+}
+
+const runColumns = `id, source, started_at, finished_at, status, error, found, new, snapshot`
+
+func scanRun(row interface{ Scan(...any) error }) (ScrapeRun, error) {
+	var r ScrapeRun
+	var finishedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.Source, &r.StartedAt, &finishedAt, &r.Status, &r.Error, &r.Found, &r.New, &r.Snapshot)
+	if finishedAt.Valid {
+		r.FinishedAt = &finishedAt.Time
+	}
+	return r, err
+}
+
+// RunFilter selects scrape runs in ListRuns. Zero values don't filter.
+type RunFilter struct {
+	Source string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// List scrape runs matching the filter, newest first, with the total number of matches
+func (d *Database) ListRuns(ctx context.Context, f RunFilter) ([]ScrapeRun, int, error) {
+	var conditions []string
+	var args []any
+	if f.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, f.Source)
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, f.Status)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM scrape_runs `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+        SELECT `+runColumns+` FROM scrape_runs `+where+`
+        ORDER BY id DESC LIMIT ? OFFSET ?
+    `, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs := []ScrapeRun{}
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, total, rows.Err()
+}
+
+// Get the last finished scrape run of a source, and the last successful one
+func (d *Database) LastRuns(ctx context.Context, source string) (last, lastSuccess *ScrapeRun, err error) {
+	query := func(where string, args ...any) (*ScrapeRun, error) {
+		r, err := scanRun(d.db.QueryRowContext(ctx, `
+            SELECT `+runColumns+` FROM scrape_runs
+            WHERE source = ? AND `+where+`
+            ORDER BY id DESC LIMIT 1
+        `, append([]any{source}, args...)...))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return &r, err
+	}
+
+	if last, err = query("status != ?", RunRunning); err != nil {
+		return nil, nil, err
+	}
+	if lastSuccess, err = query("status = ?", RunSuccess); err != nil {
+		return nil, nil, err
+	}
+	return last, lastSuccess, nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetRunCountsUpdatesOneRun(t *testing.T) {
+	d := newTestDatabase(t)
+	// Two runs of a source that are running at the same time, e.g. `scrape`
+	// next to the scheduler
+	first, err := d.StartRun("REBO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.StartRun("REBO")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetRunCounts(first, 10, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetRunSnapshot(first, "REBO-20260101-120000"); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, _, err := d.ListRuns(context.Background(), RunFilter{Source: "REBO", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2", len(runs))
+	}
+	for _, r := range runs {
+		switch r.ID {
+		case first:
+			if r.Found != 10 || r.New != 2 || r.Snapshot != "REBO-20260101-120000" {
+				t.Errorf("first run = %+v, want its counts and snapshot", r)
+			}
+		case second:
+			if r.Found != 0 || r.New != 0 || r.Snapshot != "" {
+				t.Errorf("second run = %+v, want it unchanged", r)
+			}
+		}
+	}
+}
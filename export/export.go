@@ -0,0 +1,199 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"huurwoning/db"
+)
+
+// Formats
+const (
+	CSV       = "csv"
+	JSONLines = "jsonl"
+	// CSV that Excel opens correctly with Dutch regional settings: a UTF-8
+	// byte order mark, semicolons and CRLF line endings.
+	ExcelCSV = "excel"
+)
+
+// Datasets
+const (
+	// One row per listing
+	Listings = "listings"
+	// One row per event in the history of a listing, with the listing columns
+	Events = "events"
+)
+
+// Options select what is exported and how. Since and Until filter on when a
+// listing was first seen, or for events on when the event happened.
+type Options struct {
+	Format  string
+	Dataset string
+	// Columns in order, all columns of the dataset when empty
+	Columns []string
+	Source  string
+	Since   time.Time
+	Until   time.Time
+}
+
+// row is a listing, or an event with its listing.
+type row struct {
+	property db.Property
+	event    *db.Event
+}
+
+type column struct {
+	name   string
+	events bool // only available in the events dataset
+	value  func(r row) any
+}
+
+var columns = []column{
+	{name: "id", value: func(r row) any { return r.property.ID }},
+	{name: "address", value: func(r row) any { return r.property.Address }},
+	{name: "street", value: func(r row) any { return r.property.Parts.Street }},
+	{name: "house_number", value: func(r row) any { return r.property.Parts.Number }},
+	{name: "addition", value: func(r row) any { return r.property.Parts.Addition }},
+	{name: "postcode", value: func(r row) any { return r.property.Parts.Postcode }},
+	{name: "city", value: func(r row) any { return r.property.Parts.City }},
+	{name: "lat", value: func(r row) any { return r.property.Lat }},
+	{name: "lon", value: func(r row) any { return r.property.Lon }},
+	{name: "neighbourhood", value: func(r row) any { return r.property.Neighbourhood }},
+	{name: "source", value: func(r row) any { return r.property.Source }},
+	{name: "url", value: func(r row) any { return r.property.URL }},
+	{name: "price", value: func(r row) any { return r.property.Price }},
+	{name: "area", value: func(r row) any { return r.property.Area }},
+	{name: "rooms", value: func(r row) any { return r.property.Rooms }},
+	{name: "available_from", value: func(r row) any { return formatDate(r.property.AvailableFrom) }},
+	{name: "score", value: func(r row) any { return r.property.Score }},
+	{name: "score_details", value: func(r row) any { return r.property.ScoreDetails }},
+	{name: "first_seen", value: func(r row) any { return r.property.FirstSeen }},
+	{name: "last_seen", value: func(r row) any { return r.property.LastSeen }},
+	{name: "active", value: func(r row) any { return r.property.Active }},
+	{name: "canonical_id", value: func(r row) any { return r.property.Canonical() }},
+	{name: "event", events: true, value: func(r row) any { return r.event.Type }},
+	{name: "event_details", events: true, value: func(r row) any { return r.event.Details }},
+	{name: "event_at", events: true, value: func(r row) any { return r.event.At }},
+}
+
+// formatDate writes a date without time, empty when it is unknown.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
+
+// Columns returns the names of the columns available in dataset.
+func Columns(dataset string) []string {
+	var names []string
+	for _, c := range columns {
+		if !c.events || dataset == Events {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+// Validate checks the options and fills in the defaults.
+func (o *Options) Validate() error {
+	if o.Format == "" {
+		o.Format = CSV
+	}
+	if o.Dataset == "" {
+		o.Dataset = Listings
+	}
+	o.Source = strings.ToUpper(o.Source)
+
+	if ContentType(o.Format) == "" {
+		return fmt.Errorf("unknown format %q, use %s, %s or %s", o.Format, CSV, JSONLines, ExcelCSV)
+	}
+	if o.Dataset != Listings && o.Dataset != Events {
+		return fmt.Errorf("unknown dataset %q, use %s or %s", o.Dataset, Listings, Events)
+	}
+
+	available := Columns(o.Dataset)
+	if len(o.Columns) == 0 {
+		o.Columns = available
+	}
+	for _, name := range o.Columns {
+		if !slices.Contains(available, name) {
+			return fmt.Errorf("unknown column %q for %s, use %s", name, o.Dataset, strings.Join(available, ", "))
+		}
+	}
+	return nil
+}
+
+// ContentType returns the MIME type of format, or "" for an unknown format.
+func ContentType(format string) string {
+	switch format {
+	case CSV, ExcelCSV:
+		return "text/csv; charset=utf-8"
+	case JSONLines:
+		return "application/jsonl"
+	}
+	return ""
+}
+
+// Filename returns a name for the export, e.g. listings-2024-05-01.csv.
+func Filename(o Options) string {
+	ext := ".csv"
+	if o.Format == JSONLines {
+		ext = ".jsonl"
+	}
+	return fmt.Sprintf("%s-%s%s", o.Dataset, time.Now().Format(time.DateOnly), ext)
+}
+
+// Write exports the data selected by o to w. The options must be validated.
+func Write(ctx context.Context, w io.Writer, database *db.Database, o Options) error {
+	rows, err := load(ctx, database, o)
+	if err != nil {
+		return err
+	}
+
+	selected := make([]column, len(o.Columns))
+	for i, name := range o.Columns {
+		selected[i] = columns[slices.IndexFunc(columns, func(c column) bool { return c.name == name })]
+	}
+
+	switch o.Format {
+	case JSONLines:
+		return writeJSONLines(w, selected, rows)
+	default:
+		return writeCSV(w, selected, rows, o.Format == ExcelCSV)
+	}
+}
+
+func load(ctx context.Context, database *db.Database, o Options) ([]row, error) {
+	if o.Dataset == Events {
+		events, err := database.ListEvents(ctx, db.EventFilter{Source: o.Source, Since: o.Since, Until: o.Until})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events: %v", err)
+		}
+		rows := make([]row, len(events))
+		for i := range events {
+			rows[i] = row{property: events[i].Property, event: &events[i].Event}
+		}
+		return rows, nil
+	}
+
+	// A negative limit returns everything
+	properties, _, err := database.ListProperties(ctx, db.PropertyFilter{
+		Source: o.Source,
+		Since:  o.Since,
+		Until:  o.Until,
+		Limit:  -1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list properties: %v", err)
+	}
+	rows := make([]row, len(properties))
+	for i, p := range properties {
+		rows[i] = row{property: p}
+	}
+	return rows, nil
+}
@@ -0,0 +1,133 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"huurwoning/db"
+)
+
+// newTestDatabase returns a database with two listings of REBO, the first of
+// which had its price changed.
+func newTestDatabase(t *testing.T) *db.Database {
+	t.Helper()
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	for _, l := range []db.Listing{
+		{Address: "Oudegracht 12, Utrecht", Price: 1500},
+		{Address: "Kerkstraat 1, Amersfoort", Price: 1100},
+		{Address: "Oudegracht 12, Utrecht", Price: 1450},
+	} {
+		if err := database.UpsertProperty(l, "REBO"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.SetScore("REBO", "Kerkstraat 1, Amersfoort", 0.5, "=cheap"); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func TestWrite(t *testing.T) {
+	database := newTestDatabase(t)
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{
+			name:    "csv",
+			options: Options{Columns: []string{"price", "address", "score_details"}},
+			want: "price,address,score_details\n" +
+				"1100,\"Kerkstraat 1, Amersfoort\",'=cheap\n" +
+				"1450,\"Oudegracht 12, Utrecht\",\n",
+		},
+		{
+			name:    "excel",
+			options: Options{Format: ExcelCSV, Columns: []string{"price", "address", "score_details"}},
+			want: "\ufeffprice;address;score_details\r\n" +
+				"1100;Kerkstraat 1, Amersfoort;'=cheap\r\n" +
+				"1450;Oudegracht 12, Utrecht;\r\n",
+		},
+		{
+			name:    "jsonl",
+			options: Options{Format: JSONLines, Columns: []string{"source", "price", "active", "address"}},
+			want: `{"source":"REBO","price":1100,"active":true,"address":"Kerkstraat 1, Amersfoort"}` + "\n" +
+				`{"source":"REBO","price":1450,"active":true,"address":"Oudegracht 12, Utrecht"}` + "\n",
+		},
+		{
+			name:    "events",
+			options: Options{Format: JSONLines, Dataset: Events, Source: "rebo", Columns: []string{"event", "event_details", "price"}},
+			want: `{"event":"new","event_details":"","price":1450}` + "\n" +
+				`{"event":"new","event_details":"","price":1100}` + "\n" +
+				`{"event":"price_changed","event_details":"1500 -> 1450","price":1450}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.options.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := Write(context.Background(), &buf, database, tt.options); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		wantErr string
+	}{
+		{name: "defaults", options: Options{}},
+		{name: "event columns", options: Options{Dataset: Events, Columns: []string{"address", "event_at"}}},
+		{name: "unknown format", options: Options{Format: "xlsx"}, wantErr: `unknown format "xlsx"`},
+		{name: "unknown dataset", options: Options{Dataset: "runs"}, wantErr: `unknown dataset "runs"`},
+		{name: "unknown column", options: Options{Columns: []string{"address", "rent"}}, wantErr: `unknown column "rent"`},
+		{name: "event column of listings", options: Options{Columns: []string{"event"}}, wantErr: `unknown column "event" for listings`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCSVValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{"+31 6 12345678", "'+31 6 12345678"},
+		{"-5%", "'-5%"},
+		{"@cmd", "'@cmd"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"Oudegracht 12", "Oudegracht 12"},
+		{"", ""},
+		{-5, "-5"},
+	}
+	for _, tt := range tests {
+		for _, excel := range []bool{false, true} {
+			if got := csvValue(tt.value, excel); got != tt.want {
+				t.Errorf("csvValue(%q, excel %t) = %q, want %q", tt.value, excel, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+func writeCSV(w io.Writer, columns []column, rows []row, excel bool) error {
+	if excel {
+		// Without the byte order mark Excel reads the file as ANSI and mangles €, ë, ...
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if excel {
+		cw.Comma = ';'
+		cw.UseCRLF = true
+	}
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for _, r := range rows {
+		for i, c := range columns {
+			record[i] = csvValue(c.value(r), excel)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v any, excel bool) string {
+	switch v := v.(type) {
+	case time.Time:
+		if excel {
+			// Recognised as a date by Excel, RFC 3339 is not
+			return v.Local().Format(time.DateTime)
+		}
+		return v.Format(time.RFC3339)
+	case string:
+		// Scraped text starting with = or + would be run as a formula by
+		// spreadsheets, whichever CSV format they open
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeJSONLines writes one JSON object per row, with the keys in column order.
+func writeJSONLines(w io.Writer, columns []column, rows []row) error {
+	bw := bufio.NewWriter(w)
+	// Keep "1500 -> 1600" readable
+	enc := json.NewEncoder(&jsonValue{w: bw})
+	enc.SetEscapeHTML(false)
+	for _, r := range rows {
+		bw.WriteByte('{')
+		for i, c := range columns {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			if err := enc.Encode(c.name); err != nil {
+				return err
+			}
+			bw.WriteByte(':')
+			if err := enc.Encode(c.value(r)); err != nil {
+				return err
+			}
+		}
+		bw.WriteString("}\n")
+	}
+	return bw.Flush()
+}
+
+// jsonValue drops the newline json.Encoder writes after every value.
+type jsonValue struct {
+	w *bufio.Writer
+}
+
+func (j *jsonValue) Write(p []byte) (int, error) {
+	if _, err := j.w.Write(bytes.TrimSuffix(p, []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
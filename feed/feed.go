@@ -0,0 +1,168 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"huurwoning/config"
+	"huurwoning/db"
+	"huurwoning/geo"
+	"huurwoning/logger"
+	"huurwoning/scoring"
+)
+
+const (
+	// Entries in a feed
+	feedSize = 50
+	// Listings looked at to fill a profile feed, most are filtered out
+	scanSize = 500
+)
+
+// Feed serves the newest listings as RSS 2.0 or Atom on /feed.xml, so a feed
+// reader can be used as an alert channel.
+type Feed struct {
+	store  *config.Store
+	db     *db.Database
+	logger *logger.Logger
+
+	// Evaluator of the config it was made for, made again after a reload
+	mu        sync.Mutex
+	cfg       *config.Config
+	evaluator *scoring.Evaluator
+}
+
+func New(store *config.Store, db *db.Database, globalLogger *logger.GlobalLogger) *Feed {
+	return &Feed{
+		store:  store,
+		db:     db,
+		logger: globalLogger.Logger("FEED"),
+	}
+}
+
+// ServeHTTP handles /feed.xml?source=REBO or ?profile=name, with format=atom for Atom instead of RSS.
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cfg := f.store.Config()
+
+	title := "Huurwoning: new listings"
+	filter := db.PropertyFilter{Limit: feedSize}
+	var profile *config.ProfileConfig
+	switch {
+	case q.Get("profile") != "":
+		profile = cfg.Profile(q.Get("profile"))
+		if profile == nil {
+			http.Error(w, "Unknown profile", http.StatusNotFound)
+			return
+		}
+		filter.Limit = scanSize
+		filter.Canonical = true
+		title += " for " + profile.Name
+	case q.Get("source") != "":
+		filter.Source = strings.ToUpper(q.Get("source"))
+		if cfg.Source(filter.Source) == nil {
+			http.Error(w, "Unknown source", http.StatusNotFound)
+			return
+		}
+		title += " from " + filter.Source
+	}
+
+	properties, _, err := f.db.ListProperties(r.Context(), filter)
+	if err != nil {
+		f.logger.Error("Failed to list properties", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if profile != nil {
+		properties = f.match(cfg, profile.Name, properties)
+	}
+
+	self := baseURL(r) + r.URL.RequestURI()
+	var doc any
+	var contentType string
+	if q.Get("format") == "atom" {
+		doc, contentType = atomFeed(title, self, properties), "application/atom+xml; charset=utf-8"
+	} else {
+		doc, contentType = rssFeed(title, self, properties), "application/rss+xml; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		f.logger.Error("Failed to write feed", "error", err)
+	}
+}
+
+// match returns the first feedSize properties that belong to the profile,
+// leaving out the ones a rule of the profile ignores.
+func (f *Feed) match(cfg *config.Config, profile string, properties []db.Property) []db.Property {
+	evaluator := f.evaluatorFor(cfg)
+	p := cfg.Profile(profile)
+	matching := properties[:0]
+	for _, property := range properties {
+		if len(matching) == feedSize {
+			break
+		}
+		// Evaluating estimates the commute, so the other listings are left out first
+		if !p.Match(property.Source, property.Address, geo.PropertyLocation(property)) {
+			continue
+		}
+		evaluation, ok, err := evaluator.Evaluate(property)
+		if err != nil {
+			f.logger.Warn("Failed to estimate commute", "address", property.Address, "error", err)
+		}
+		if ok && slices.Contains(evaluation.Profiles, profile) {
+			matching = append(matching, property)
+		}
+	}
+	return matching
+}
+
+// evaluatorFor returns the evaluator for cfg, made when the config was reloaded.
+func (f *Feed) evaluatorFor(cfg *config.Config) *scoring.Evaluator {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cfg != cfg {
+		evaluator, err := scoring.NewEvaluator(cfg)
+		if err != nil {
+			f.logger.Error("Estimating commute times in a straight line", "error", err)
+		}
+		f.cfg, f.evaluator = cfg, evaluator
+	}
+	return f.evaluator
+}
+
+// guid identifies a listing in feeds. It only depends on the database id, so
+// readers don't show a listing twice when its price or address changes.
+func guid(p db.Property) string {
+	return fmt.Sprintf("urn:huurwoning:property:%d", p.ID)
+}
+
+func summary(p db.Property) string {
+	if p.Price > 0 {
+		return fmt.Sprintf("%s, € %d per month", p.Source, p.Price)
+	}
+	return p.Source
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// updated is the time of the newest entry, or now for an empty feed.
+func updated(properties []db.Property) time.Time {
+	if len(properties) == 0 {
+		return time.Now()
+	}
+	return properties[0].FirstSeen
+}
@@ -0,0 +1,113 @@
+package feed
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"huurwoning/config"
+	"huurwoning/db"
+	"huurwoning/logger"
+)
+
+const testConfig = `
+profiles:
+  - name: anna
+    sources: [REBO, VESTEDA]
+    filters:
+      include: ["Utrecht"]
+    rules:
+      - when: price > 2000
+        ignore: true
+`
+
+// newTestFeed returns a feed of listings in Utrecht and Amersfoort, one of
+// which is listed by both sources.
+func newTestFeed(t *testing.T) *Feed {
+	t.Helper()
+	t.Setenv("USER_NAME", "test@example.com")
+	t.Setenv("REBO_PW", "secret")
+	t.Setenv("VESTEDA_PW", "secret")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := config.NewStore(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	for _, l := range []struct {
+		source  string
+		address string
+		price   int
+	}{
+		{"REBO", "Oudegracht 12, Utrecht", 1500},
+		{"REBO", "Biltstraat 5, Utrecht", 2400},
+		{"REBO", "Kerkstraat 1, Amersfoort", 1100},
+		{"VESTEDA", "Oudegracht 12, Utrecht", 1500},
+		{"VESTEDA", "Vredenburg 3, Utrecht", 1900},
+	} {
+		if err := database.UpsertProperty(db.Listing{Address: l.address, Price: l.price}, l.source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	globalLogger, err := logger.NewGlobalLogger(config.LoggingConfig{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(store, database, globalLogger)
+}
+
+func TestFeed(t *testing.T) {
+	f := newTestFeed(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Vredenburg 3, Utrecht", "Oudegracht 12, Utrecht", "Kerkstraat 1, Amersfoort", "Biltstraat 5, Utrecht", "Oudegracht 12, Utrecht"}},
+		{"source=vesteda", []string{"Vredenburg 3, Utrecht", "Oudegracht 12, Utrecht"}},
+		// Without the duplicate of VESTEDA and the listing the rule ignores
+		{"profile=anna", []string{"Vredenburg 3, Utrecht", "Oudegracht 12, Utrecht"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			f.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed.xml?"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var doc rss
+			if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range doc.Channel.Items {
+				got = append(got, item.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("items = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeedUnknown(t *testing.T) {
+	f := newTestFeed(t)
+	for _, query := range []string{"profile=ben", "source=funda"} {
+		w := httptest.NewRecorder()
+		f.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed.xml?"+query, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", query, w.Code)
+		}
+	}
+}
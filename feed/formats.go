@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+
+	"huurwoning/db"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func rssFeed(title, self string, properties []db.Property) rss {
+	items := make([]rssItem, len(properties))
+	for i, p := range properties {
+		items[i] = rssItem{
+			Title:       p.Address,
+			Link:        p.URL,
+			GUID:        rssGUID{Value: guid(p)},
+			PubDate:     p.FirstSeen.Format(time.RFC1123Z),
+			Description: summary(p),
+			Category:    p.Source,
+		}
+	}
+	return rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         title,
+			Link:          self,
+			Description:   "New rental listings",
+			LastBuildDate: updated(properties).Format(time.RFC1123Z),
+			Items:         items,
+		},
+	}
+}
+
+type atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title    string       `xml:"title"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Link     *atomLink    `xml:"link,omitempty"`
+	Summary  string       `xml:"summary"`
+	Category atomCategory `xml:"category"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func atomFeed(title, self string, properties []db.Property) atom {
+	entries := make([]atomEntry, len(properties))
+	for i, p := range properties {
+		entries[i] = atomEntry{
+			Title:    p.Address,
+			ID:       guid(p),
+			Updated:  p.FirstSeen.Format(time.RFC3339),
+			Summary:  summary(p),
+			Category: atomCategory{Term: p.Source},
+		}
+		if p.URL != "" {
+			entries[i].Link = &atomLink{Href: p.URL}
+		}
+	}
+	return atom{
+		Title:   title,
+		ID:      self,
+		Updated: updated(properties).Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: self},
+		Author:  atomAuthor{Name: "huurwoning"},
+		Entries: entries,
+	}
+}
@@ -0,0 +1,150 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+const earthRadiusKM = 6371.0
+
+// Distance returns the distance between a and b in kilometres, along the earth's surface.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := radians(b.Lon - a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Sqrt(h))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Polygon is an outer ring followed by its holes, like in GeoJSON.
+type Polygon [][]Point
+
+// Contains reports whether p is inside the outer ring and not in a hole.
+func (poly Polygon) Contains(p Point) bool {
+	if len(poly) == 0 || !inRing(poly[0], p) {
+		return false
+	}
+	for _, hole := range poly[1:] {
+		if inRing(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// inRing casts a ray from p and counts the edges it crosses. At the scale of
+// a city, treating coordinates as flat is accurate enough.
+func inRing(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Features    []geoJSON       `json:"features"`
+	Geometries  []geoJSON       `json:"geometries"`
+}
+
+// ParseGeoJSON returns the polygons in a GeoJSON document: a Polygon or
+// MultiPolygon, or a Feature, FeatureCollection or GeometryCollection of them.
+// Other geometries are ignored.
+func ParseGeoJSON(data []byte) ([]Polygon, error) {
+	var doc geoJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+	polygons, err := doc.polygons()
+	if err != nil {
+		return nil, err
+	}
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("no Polygon or MultiPolygon found")
+	}
+	return polygons, nil
+}
+
+func (g geoJSON) polygons() ([]Polygon, error) {
+	switch g.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return nil, fmt.Errorf("invalid Polygon: %v", err)
+		}
+		polygon, err := toPolygon(rings)
+		if err != nil {
+			return nil, err
+		}
+		return []Polygon{polygon}, nil
+
+	case "MultiPolygon":
+		var multi [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &multi); err != nil {
+			return nil, fmt.Errorf("invalid MultiPolygon: %v", err)
+		}
+		var polygons []Polygon
+		for _, rings := range multi {
+			polygon, err := toPolygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, polygon)
+		}
+		return polygons, nil
+
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, nil
+		}
+		return g.Geometry.polygons()
+
+	case "FeatureCollection", "GeometryCollection":
+		var polygons []Polygon
+		for _, child := range append(g.Features, g.Geometries...) {
+			p, err := child.polygons()
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, p...)
+		}
+		return polygons, nil
+	}
+	return nil, nil
+}
+
+// toPolygon converts GeoJSON rings of [lon, lat] positions.
+func toPolygon(rings [][][]float64) (Polygon, error) {
+	polygon := make(Polygon, len(rings))
+	for i, ring := range rings {
+		if len(ring) < 4 {
+			return nil, fmt.Errorf("a polygon ring needs at least 4 positions, got %d", len(ring))
+		}
+		polygon[i] = make([]Point, len(ring))
+		for j, position := range ring {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("a position needs a longitude and latitude")
+			}
+			polygon[i][j] = Point{Lat: position[1], Lon: position[0]}
+		}
+	}
+	return polygon, nil
+}
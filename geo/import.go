@@ -0,0 +1,104 @@
+package geo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"huurwoning/db"
+)
+
+// Column names recognised in a location dataset, lowercase. Only lat, lon and
+// a postcode or street are required.
+var headers = map[string][]string{
+	"postcode":      {"postcode", "pc6", "zipcode"},
+	"house_number":  {"house_number", "huisnummer", "number", "huisnr"},
+	"street":        {"street", "straat", "straatnaam", "openbareruimte", "openbare_ruimte"},
+	"city":          {"city", "woonplaats", "plaats", "woonplaatsnaam"},
+	"lat":           {"lat", "latitude", "breedtegraad"},
+	"lon":           {"lon", "lng", "long", "longitude", "lengtegraad"},
+	"neighbourhood": {"neighbourhood", "neighborhood", "buurt", "buurtnaam", "wijk", "wijknaam"},
+}
+
+// Import reads a CSV dataset of addresses with coordinates, e.g. an export of
+// the BAG, and stores it in the database. The first line must be a header,
+// the separator can be a comma or a semicolon.
+func Import(r io.Reader, database *db.Database, replace bool) (int, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(4096)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	cr := csv.NewReader(br)
+	if line, _, _ := strings.Cut(string(first), "\n"); strings.Count(line, ";") > strings.Count(line, ",") {
+		cr.Comma = ';'
+	}
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := findColumns(header)
+	if columns["lat"] < 0 || columns["lon"] < 0 {
+		return 0, fmt.Errorf("the header needs lat and lon columns, got %s", strings.Join(header, ", "))
+	}
+	if columns["postcode"] < 0 && columns["street"] < 0 {
+		return 0, fmt.Errorf("the header needs a postcode or street column, got %s", strings.Join(header, ", "))
+	}
+
+	line := 1
+	return database.ImportLocations(replace, func() (db.Location, error) {
+		record, err := cr.Read()
+		if err != nil {
+			return db.Location{}, err
+		}
+		line++
+
+		get := func(name string) string {
+			if i := columns[name]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		l := db.Location{
+			Postcode:      strings.ToUpper(strings.ReplaceAll(get("postcode"), " ", "")),
+			HouseNumber:   strings.TrimLeft(get("house_number"), "0"),
+			Street:        get("street"),
+			City:          get("city"),
+			Neighbourhood: get("neighbourhood"),
+		}
+		if l.Lat, err = parseCoordinate(get("lat")); err != nil {
+			return l, fmt.Errorf("line %d: lat: %w", line, err)
+		}
+		if l.Lon, err = parseCoordinate(get("lon")); err != nil {
+			return l, fmt.Errorf("line %d: lon: %w", line, err)
+		}
+		return l, nil
+	})
+}
+
+func findColumns(header []string) map[string]int {
+	columns := make(map[string]int)
+	for name, aliases := range headers {
+		columns[name] = -1
+		for i, h := range header {
+			h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+			for _, alias := range aliases {
+				if h == alias {
+					columns[name] = i
+				}
+			}
+		}
+	}
+	return columns
+}
+
+// parseCoordinate accepts a decimal point or comma.
+func parseCoordinate(v string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+}
@@ -0,0 +1,33 @@
+package geo
+
+import (
+	"huurwoning/address"
+	"huurwoning/db"
+)
+
+// PropertyLocation returns where a stored property is, or nil when it wasn't geocoded.
+func PropertyLocation(p db.Property) *Point {
+	if !p.HasLocation() {
+		return nil
+	}
+	return &Point{Lat: p.Lat, Lon: p.Lon}
+}
+
+// Locate geocodes an address with the imported locations, nil when it isn't found.
+func Locate(database *db.Database, text string) (*Point, error) {
+	l, ok, err := database.LookupLocation(address.Parse(text))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &Point{Lat: l.Lat, Lon: l.Lon}, nil
+}
+
+// LocateProperty geocodes an unsaved property, leaving it as is when it isn't found.
+func LocateProperty(database *db.Database, p *db.Property) error {
+	l, ok, err := database.LookupLocation(p.Parts)
+	if err != nil || !ok {
+		return err
+	}
+	p.Lat, p.Lon, p.Neighbourhood = l.Lat, l.Lon, l.Neighbourhood
+	return nil
+}
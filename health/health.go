@@ -0,0 +1,174 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"huurwoning/browser"
+	"huurwoning/config"
+	"huurwoning/db"
+)
+
+const checkTimeout = 5 * time.Second
+
+// Checker tracks the scrape results per source and answers the liveness and
+// readiness probes.
+type Checker struct {
+	store   *config.Store
+	browser *browser.Browser
+	db      *db.Database
+	started time.Time
+
+	mu      sync.Mutex
+	sources map[string]*sourceStatus
+}
+
+type sourceStatus struct {
+	lastRun     time.Time
+	lastSuccess time.Time
+	lastError   string
+}
+
+type Check struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type SourceCheck struct {
+	OK          bool       `json:"ok"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	MaxAge      string     `json:"max_age"`
+}
+
+type Report struct {
+	Status   string                 `json:"status"`
+	Browser  Check                  `json:"browser"`
+	Database Check                  `json:"database"`
+	Sources  map[string]SourceCheck `json:"sources"`
+}
+
+func New(store *config.Store, b *browser.Browser, db *db.Database) *Checker {
+	return &Checker{
+		store:   store,
+		browser: b,
+		db:      db,
+		started: time.Now(),
+		sources: make(map[string]*sourceStatus),
+	}
+}
+
+// RecordScrape stores the result of a scrape run of source.
+func (c *Checker) RecordScrape(source string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status, ok := c.sources[source]
+	if !ok {
+		status = &sourceStatus{}
+		c.sources[source] = status
+	}
+
+	status.lastRun = time.Now()
+	if err != nil {
+		status.lastError = err.Error()
+		return
+	}
+	status.lastSuccess = status.lastRun
+	status.lastError = ""
+}
+
+// MaxAge is how long a source may go without a successful scrape before the
+// scraper is not ready: server.max_scrape_age, but at least three intervals.
+func MaxAge(cfg *config.Config, source config.SourceConfig) time.Duration {
+	maxAge := cfg.Server.MaxScrapeAge
+	if min := 3 * cfg.SourceInterval(source); maxAge < min {
+		maxAge = min
+	}
+	return maxAge
+}
+
+// Ready runs all readiness checks.
+func (c *Checker) Ready(ctx context.Context) Report {
+	report := Report{
+		Status:  "ready",
+		Sources: make(map[string]SourceCheck),
+	}
+
+	if err := c.browser.Ping(checkTimeout); err != nil {
+		report.Browser = Check{Error: err.Error()}
+	} else {
+		report.Browser = Check{OK: true}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	if err := c.db.CheckWritable(ctx); err != nil {
+		report.Database = Check{Error: err.Error()}
+	} else {
+		report.Database = Check{OK: true}
+	}
+
+	ready := report.Browser.OK && report.Database.OK
+
+	cfg := c.store.Config()
+	c.mu.Lock()
+	for _, source := range cfg.EnabledSources() {
+		maxAge := MaxAge(cfg, source)
+		check := SourceCheck{MaxAge: maxAge.String()}
+
+		// A source that never succeeded is measured from the start of the process
+		since := c.started
+		if status, ok := c.sources[source.Name]; ok {
+			if !status.lastRun.IsZero() {
+				lastRun := status.lastRun
+				check.LastRun = &lastRun
+			}
+			if !status.lastSuccess.IsZero() {
+				lastSuccess := status.lastSuccess
+				check.LastSuccess = &lastSuccess
+				since = lastSuccess
+			}
+			check.LastError = status.lastError
+		}
+		check.OK = time.Since(since) <= maxAge
+
+		ready = ready && check.OK
+		report.Sources[source.Name] = check
+	}
+	c.mu.Unlock()
+
+	if !ready {
+		report.Status = "not ready"
+	}
+	return report
+}
+
+// Healthz reports that the process is up and serving.
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "ok",
+		"uptime": time.Since(c.started).Round(time.Second).String(),
+	})
+}
+
+// Readyz reports whether the browser, database and every source are healthy,
+// with 503 when they are not.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := c.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -1,48 +1,94 @@
 package logger
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
-	"time"
+
+	"huurwoning/config"
 )
 
 type Severity string
 
 const (
-	SeverityDefault   Severity = "DEFAULT"
-	SeverityDebug     Severity = "DEBUG"
-	SeverityInfo      Severity = "INFO"
-	SeverityNotice    Severity = "NOTICE"
-	SeverityWarning   Severity = "WARNING"
-	SeverityError     Severity = "ERROR"
-	SeverityCritical  Severity = "CRITICAL"
-	SeverityAlert     Severity = "ALERT"
-	SeverityEmergency Severity = "EMERGENCY"
+	SeverityDebug   Severity = "DEBUG"
+	SeverityInfo    Severity = "INFO"
+	SeverityWarning Severity = "WARNING"
+	SeverityError   Severity = "ERROR"
 )
 
-type LogFunc func(msg string, args ...any)
-
+// Logger logs for one module. Arguments after the message are key/value
+// pairs, e.g. logger.Error("Failed to upsert property", "error", err).
 type Logger struct {
-	Debug  LogFunc
-	Info   LogFunc
-	Warn   LogFunc
-	Error  LogFunc
-	name   string
-	stdout *log.Logger
+	name  string
+	level *slog.LevelVar
+	slog  *slog.Logger
+}
+
+func (l *Logger) Debug(msg string, args ...any) {
+	l.slog.Debug(msg, args...)
+}
+
+func (l *Logger) Info(msg string, args ...any) {
+	l.slog.Info(msg, args...)
+}
+
+func (l *Logger) Warn(msg string, args ...any) {
+	l.slog.Warn(msg, args...)
+}
+
+func (l *Logger) Error(msg string, args ...any) {
+	l.slog.Error(msg, args...)
 }
 
 type GlobalLogger struct {
-	loggers map[string]*Logger
-	mu      sync.Mutex
+	loggers  map[string]*Logger
+	mu       sync.Mutex
+	redactor *redactor
+	handler  slog.Handler
+	file     *rotatingFile
+
+	level   slog.Level
+	modules map[string]slog.Level
 }
 
-func NewGlobalLogger() (*GlobalLogger, error) {
-	return &GlobalLogger{
-		loggers: make(map[string]*Logger),
-	}, nil
+// NewGlobalLogger creates the loggers' shared output: stdout, and a rotating
+// log file when one is configured.
+func NewGlobalLogger(cfg config.LoggingConfig) (*GlobalLogger, error) {
+	gl := &GlobalLogger{
+		loggers:  make(map[string]*Logger),
+		redactor: &redactor{},
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.File.Path != "" {
+		file, err := newRotatingFile(cfg.File.Path, int64(cfg.File.MaxSizeMB)*1024*1024, cfg.File.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		gl.file = file
+		out = io.MultiWriter(os.Stdout, file)
+	}
+
+	opts := &slog.HandlerOptions{
+		// Filtering happens per module in moduleHandler
+		Level:       slog.LevelDebug,
+		ReplaceAttr: replaceAttr,
+	}
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(out, opts)
+	} else {
+		handler = slog.NewJSONHandler(out, opts)
+	}
+	gl.handler = &redactHandler{next: handler, redactor: gl.redactor}
+
+	gl.SetLevels(cfg)
+	return gl, nil
 }
 
 func (gl *GlobalLogger) Logger(moduleName string) *Logger {
@@ -53,58 +99,118 @@ func (gl *GlobalLogger) Logger(moduleName string) *Logger {
 		return logger
 	}
 
-	stdout := log.New(os.Stdout, "", 0)
+	level := &slog.LevelVar{}
+	level.Set(gl.levelFor(moduleName))
+
+	handler := &moduleHandler{next: gl.handler, level: level}
 	l := &Logger{
-		name:   moduleName,
-		stdout: stdout,
+		name:  moduleName,
+		level: level,
+		// Key/value pairs are grouped under "fields", next to the module name
+		slog: slog.New(handler).With("module", moduleName).WithGroup("fields"),
 	}
 
-	l.Debug = l.logFunc(SeverityDebug)
-	l.Info = l.logFunc(SeverityInfo)
-	l.Warn = l.logFunc(SeverityWarning)
-	l.Error = l.logFunc(SeverityError)
-
 	gl.loggers[moduleName] = l
 	return l
 }
 
+// SetLevels applies the (default and per module) minimum levels of cfg to all
+// loggers, including the ones that already exist. Output format and file
+// changes need a new GlobalLogger.
+func (gl *GlobalLogger) SetLevels(cfg config.LoggingConfig) {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+
+	gl.level = parseLevel(cfg.Level)
+	gl.modules = make(map[string]slog.Level, len(cfg.Modules))
+	for module, level := range cfg.Modules {
+		gl.modules[strings.ToUpper(module)] = parseLevel(level)
+	}
+
+	for name, l := range gl.loggers {
+		l.level.Set(gl.levelFor(name))
+	}
+}
+
+// SetSecrets replaces the secret values that are masked in all log output.
+func (gl *GlobalLogger) SetSecrets(secrets ...string) {
+	gl.redactor.setSecrets(secrets)
+}
+
 func (gl *GlobalLogger) Close() {
-	// No need to close anything
-}
-
-// logFunc creates a logging function that writes structured logs to stdout
-func (l *Logger) logFunc(severity Severity) LogFunc {
-	return func(msg string, args ...interface{}) {
-		// Create the log entry structure
-		entry := struct {
-			Timestamp string                 `json:"timestamp"`
-			Level     string                 `json:"level"`
-			Module    string                 `json:"module"`
-			Message   string                 `json:"message"`
-			Fields    map[string]interface{} `json:"fields,omitempty"`
-		}{
-			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
-			Level:     string(severity),
-			Module:    l.name,
-			Message:   fmt.Sprintf(msg, args...),
-			Fields:    make(map[string]interface{}),
-		}
+	if gl.file != nil {
+		gl.file.Close()
+	}
+}
 
-		// Add additional fields from args
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				entry.Fields[args[i].(string)] = args[i+1]
-			}
-		}
+func (gl *GlobalLogger) levelFor(moduleName string) slog.Level {
+	if level, ok := gl.modules[moduleName]; ok {
+		return level
+	}
+	return gl.level
+}
 
-		// Marshal to JSON
-		jsonData, err := json.Marshal(entry)
-		if err != nil {
-			l.stdout.Printf("Error marshaling log entry: %v", err)
-			return
-		}
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
 
-		// Write to stdout
-		l.stdout.Println(string(jsonData))
+// replaceAttr keeps the field names and severities of the original JSON format.
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		a.Key = "timestamp"
+		a.Value = slog.StringValue(a.Value.Time().UTC().Format("2006-01-02T15:04:05.999999999Z07:00"))
+	case slog.LevelKey:
+		a.Value = slog.StringValue(string(severity(a.Value.Any().(slog.Level))))
+	case slog.MessageKey:
+		a.Key = "message"
 	}
+	return a
+}
+
+func severity(level slog.Level) Severity {
+	switch {
+	case level >= slog.LevelError:
+		return SeverityError
+	case level >= slog.LevelWarn:
+		return SeverityWarning
+	case level >= slog.LevelInfo:
+		return SeverityInfo
+	default:
+		return SeverityDebug
+	}
+}
+
+// moduleHandler drops records below the minimum level of its module.
+type moduleHandler struct {
+	next  slog.Handler
+	level *slog.LevelVar
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &moduleHandler{next: h.next.WithAttrs(attrs), level: h.level}
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return &moduleHandler{next: h.next.WithGroup(name), level: h.level}
 }
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// Secrets shorter than this are not masked, it would mangle every message.
+const minSecretLength = 4
+
+// sensitiveKeys are field keys whose values are always masked. A key matches
+// when it contains one of them, e.g. "smtp_password".
+var sensitiveKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"api_key",
+	"apikey",
+	"authorization",
+	"cookie",
+}
+
+// redactor masks secrets in log messages and fields before they are written.
+type redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+func (r *redactor) setSecrets(secrets []string) {
+	filtered := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		if len(s) >= minSecretLength {
+			filtered = append(filtered, s)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = filtered
+}
+
+// String replaces every known secret value in s.
+func (r *redactor) String(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// Attr masks the value of a sensitive key, and secret values in anything
+// that is logged as text.
+func (r *redactor) Attr(a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.String(a.Value.String()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redactedAttrs := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redactedAttrs[i] = r.Attr(attr)
+		}
+		a.Value = slog.GroupValue(redactedAttrs...)
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(r.String(v.Error()))
+		case fmt.Stringer:
+			a.Value = slog.StringValue(r.String(v.String()))
+		}
+	case slog.KindLogValuer:
+		a.Value = a.Value.Resolve()
+		return r.Attr(a)
+	}
+	return a
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHandler masks secrets in the message and attributes of every record.
+type redactHandler struct {
+	next     slog.Handler
+	redactor *redactor
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redactedRecord := slog.NewRecord(r.Time, r.Level, h.redactor.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redactedRecord.AddAttrs(h.redactor.Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redactedRecord)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = h.redactor.Attr(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redactedAttrs), redactor: h.redactor}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
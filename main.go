@@ -1,37 +1,77 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"huurwoning/api"
 	"huurwoning/beumer"
 	"huurwoning/bouwinvest"
 	"huurwoning/browser"
 	"huurwoning/config"
+	"huurwoning/dashboard"
 	"huurwoning/db"
+	"huurwoning/feed"
+	"huurwoning/health"
 	"huurwoning/logger"
+	"huurwoning/metrics"
 	"huurwoning/rebo"
+	"huurwoning/server"
 	"huurwoning/vesteda"
 )
 
+// sourceFunc scrapes a source, runID is the scrape run to record counts and
+// snapshots on, 0 for a dry run.
+type sourceFunc func(b *browser.Browser, globalLogger *logger.GlobalLogger, config *config.Config, db *db.Database, runID int64) ([]db.Listing, error)
+
+var sources = map[string]sourceFunc{
+	"REBO":       rebo.Rebo,
+	"VESTEDA":    vesteda.Vesteda,
+	"BOUWINVEST": bouwinvest.BouwInvest,
+	"BEUMER":     beumer.Beumer,
+}
+
 func main() {
-	config, err := config.LoadConfig()
+	// Without a command the scraper runs, like it always did
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(command(args))
+}
+
+// runCommand implements `run`: scrape every enabled source on its interval until stopped.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.Parse(args)
+
+	store, err := loadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	cfg := store.Config()
 
-	globalLogger, err := logger.NewGlobalLogger()
+	globalLogger, err := logger.NewGlobalLogger(cfg.Logging)
 	if err != nil {
 		log.Fatalf("Failed to create global logger: %v", err)
 	}
 	defer globalLogger.Close()
+	globalLogger.SetSecrets(cfg.SecretValues()...)
 
-	dbPath := config.DB_PATH
-	if config.ENVIRONMENT == "development" {
-		dbPath = "./data/properties.db"
-	}
-
-	database, err := db.New(dbPath)
+	database, err := db.New(cfg.DBPath)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -39,34 +79,127 @@ func main() {
 
 	logger := globalLogger.Logger("MAIN")
 
-	b, err := browser.New(config.DEBUG_MODE, globalLogger)
+	b, err := browser.New(cfg.Browser, cfg.Debug, globalLogger)
 	if err != nil {
 		log.Fatalf("Failed to create browser: %v", err)
 	}
 	defer b.Close()
 
-	// Start separate timers for each scraping process
-	for {
-		err := rebo.Rebo(b, globalLogger, "https://rebowonenhuur.nl/login", database)
+	checker := health.New(store, b, database)
+
+	if cfg.Server.Listen != "" {
+		srv := server.New(cfg.Server.Listen, globalLogger)
+		srv.Handle("/metrics", metrics.Handler())
+		srv.Handle("GET /healthz", http.HandlerFunc(checker.Healthz))
+		srv.Handle("GET /readyz", http.HandlerFunc(checker.Readyz))
+		srv.Handle("/api/", api.New(store, database, globalLogger).Handler())
+		dash, err := dashboard.New(store, database, globalLogger)
 		if err != nil {
-			logger.Error("Error in Rebo scraping!", "error", err)
+			log.Fatalf("Failed to create dashboard: %v", err)
 		}
+		srv.Handle("/", dash.Handler())
+		srv.Handle("GET /feed.xml", dash.Protect(feed.New(store, database, globalLogger)))
+		srv.Start()
+		defer srv.Close()
+	}
 
-		err = vesteda.Vesteda(b, globalLogger, "https://hurenbij.vesteda.com/login", database)
+	// Reload the config on SIGHUP or when the file changes. Schedules,
+	// filters and notifiers are picked up at the next run, the browser keeps running.
+	reloaded := make(chan struct{}, 1)
+	go func() {
+		err := store.Watch(context.Background(), func(old, new *config.Config, err error) {
+			if err != nil {
+				logger.Error("Failed to reload config, keeping the current one", "error", err)
+				return
+			}
+			globalLogger.SetSecrets(new.SecretValues()...)
+			globalLogger.SetLevels(new.Logging)
+			logger.Info("Config reloaded")
+			if old.Browser != new.Browser || old.DBPath != new.DBPath || old.Server.Listen != new.Server.Listen {
+				logger.Warn("Browser, database and server settings only apply after a restart")
+			}
+			if old.Logging.Format != new.Logging.Format || old.Logging.File != new.Logging.File {
+				logger.Warn("Log format and file settings only apply after a restart")
+			}
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		})
 		if err != nil {
-			logger.Error("Error in Vesteda scraping!", "error", err)
+			logger.Error("Config reloading disabled", "error", err)
 		}
+	}()
 
-		err = bouwinvest.BouwInvest(b, globalLogger, "https://www.wonenbijbouwinvest.nl/huuraanbod?query=Utrecht&range=10&seniorservice=false&order=recent&size=50", database)
-		if err != nil {
-			logger.Error("Error in BouwInvest scraping!", "error", err)
+	// Every source runs on its own interval
+	lastRun := make(map[string]time.Time)
+	for {
+		cfg := store.Config()
+		for _, source := range cfg.EnabledSources() {
+			if time.Since(lastRun[source.Name]) < cfg.SourceInterval(source) {
+				continue
+			}
+
+			runSource(source.Name, b, globalLogger, cfg, database, checker)
+			lastRun[source.Name] = time.Now()
+		}
+
+		select {
+		case <-time.After(untilNextRun(cfg, lastRun)):
+		case <-reloaded:
 		}
+	}
+}
+
+// runSource runs one scrape of source and records its result. A dry run is
+// not stored as a scrape run.
+func runSource(name string, b *browser.Browser, globalLogger *logger.GlobalLogger, cfg *config.Config, database *db.Database, checker *health.Checker) ([]db.Listing, error) {
+	logger := globalLogger.Logger("MAIN")
 
-		err = beumer.Beumer(b, globalLogger, "https://www.beumer.nl/huurwoningen/?search=Utrecht&status%5B0%5D=te-huur", database)
+	var runID int64
+	if !cfg.SourceDryRun(*cfg.Source(name)) {
+		var err error
+		runID, err = database.StartRun(name)
 		if err != nil {
-			logger.Error("Error in Beumer scraping!", "error", err)
+			logger.Error("Failed to start scrape run", "error", err)
+		}
+	}
+
+	start := time.Now()
+	listings, err := sources[name](b, globalLogger, cfg, database, runID)
+	metrics.ObserveScrape(name, time.Since(start), err)
+	checker.RecordScrape(name, err)
+	if runID != 0 {
+		if err := database.FinishRun(runID, err); err != nil {
+			logger.Error("Failed to finish scrape run", "error", err)
+		}
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in %s scraping!", name), "error", err)
+	}
+	return listings, err
+}
+
+// untilNextRun returns how long to wait before the first source is due again.
+func untilNextRun(c *config.Config, lastRun map[string]time.Time) time.Duration {
+	wait := time.Duration(-1)
+	for _, source := range c.EnabledSources() {
+		if d := time.Until(lastRun[source.Name].Add(c.SourceInterval(source))); wait < 0 || d < wait {
+			wait = d
 		}
+	}
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
 
-		time.Sleep(30 * time.Second)
+// checkSources makes sure every configured source has a scraper.
+func checkSources(c *config.Config) error {
+	for _, s := range c.Sources {
+		if _, ok := sources[s.Name]; !ok {
+			return fmt.Errorf("unknown source %q", s.Name)
+		}
 	}
+	return nil
 }
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "huurwoning"
+
+var (
+	ScrapeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scrape_duration_seconds",
+		Help:      "Duration of a scrape run per source.",
+		Buckets:   []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"source"})
+
+	Scrapes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scrapes_total",
+		Help:      "Scrape runs per source and result (success or failure).",
+	}, []string{"source", "result"})
+
+	ListingsFound = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "listings_found",
+		Help:      "Listings found in the last scrape run per source.",
+	}, []string{"source"})
+
+	NewListings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "new_listings_total",
+		Help:      "Listings seen for the first time per source.",
+	}, []string{"source"})
+
+	AlertsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_sent_total",
+		Help:      "Alerts sent per channel and result (success or failure).",
+	}, []string{"channel", "result"})
+
+	BrowserRestarts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "browser_restarts_total",
+		Help:      "Times the browser was recreated after it died.",
+	})
+
+	BrowserOpenTabs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "browser_open_tabs",
+		Help:      "Tabs currently open in the browser.",
+	})
+)
+
+// ObserveScrape records the duration and result of a scrape run.
+func ObserveScrape(source string, duration time.Duration, err error) {
+	ScrapeDuration.WithLabelValues(source).Observe(duration.Seconds())
+	Scrapes.WithLabelValues(source, result(err)).Inc()
+}
+
+// ObserveAlert records an alert sent through channel.
+func ObserveAlert(channel string, err error) {
+	AlertsSent.WithLabelValues(channel, result(err)).Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,62 @@
+package photos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const downloadTimeout = 30 * time.Second
+
+// Downloader fetches photos into a store.
+type Downloader struct {
+	Store *Store
+	// Larger photos are skipped
+	MaxBytes  int64
+	UserAgent string
+	Client    *http.Client
+}
+
+func NewDownloader(store *Store, maxBytes int64, userAgent string) *Downloader {
+	return &Downloader{
+		Store:     store,
+		MaxBytes:  maxBytes,
+		UserAgent: userAgent,
+		Client:    &http.Client{Timeout: downloadTimeout},
+	}
+}
+
+// Download fetches the photo at url and returns its name in the store.
+func (d *Downloader) Download(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if d.UserAgent != "" {
+		req.Header.Set("User-Agent", d.UserAgent)
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > d.MaxBytes {
+		return "", fmt.Errorf("photo is %d bytes, more than %d", resp.ContentLength, d.MaxBytes)
+	}
+
+	// The length isn't always sent, so the body is limited too
+	data, err := io.ReadAll(io.LimitReader(resp.Body, d.MaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > d.MaxBytes {
+		return "", fmt.Errorf("photo is more than %d bytes", d.MaxBytes)
+	}
+	return d.Store.Save(data)
+}
@@ -0,0 +1,80 @@
+package photos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Extensions of the image types that are stored, by content type
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var namePattern = regexp.MustCompile(`^[0-9a-f]{64}\.(jpg|png|gif|webp)$`)
+
+// Store keeps photos in a directory under the SHA-256 of their content, so
+// the same photo used by several listings or sources is stored once. A
+// photo's name is the hash with an extension for its type, e.g.
+// "3a7bd3e2...c1.jpg", stored as 3a/3a7bd3e2...c1.jpg.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save stores an image and returns its name. Other content is refused.
+func (s *Store) Save(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return "", fmt.Errorf("not a supported image: %s", contentType)
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:]) + ext
+	path, _ := s.Path(name)
+	if _, err := os.Stat(path); err == nil {
+		return name, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	// Written next to it first, so a photo is never half there
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".photo-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Path returns the file of a photo by name. Names that Save can't have
+// returned are an error, so a name from a request can't point elsewhere.
+func (s *Store) Path(name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", errors.New("invalid photo name")
+	}
+	return filepath.Join(s.dir, name[:2], name), nil
+}
@@ -1,91 +1,212 @@
 package reporting
 
 import (
+	"errors"
 	"fmt"
 	"huurwoning/config"
 	"huurwoning/logger"
+	"huurwoning/metrics"
 	"net/smtp"
+	"slices"
+	"strings"
 
 	"github.com/jordan-wright/email"
 	"github.com/twilio/twilio-go"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
-func SendAlert(newAdress string, prefix string, logger *logger.Logger) {
+var errNotifierDisabled = errors.New("notifier disabled")
+
+// Reporter sends alerts through the notifiers of the config it was created with.
+type Reporter struct {
+	config config.NotifiersConfig
+	// Notifiers chosen with Only, nil when all of them are used
+	only []string
+}
+
+func New(config config.NotifiersConfig) *Reporter {
+	return &Reporter{config: config}
+}
+
+// Only returns a reporter that sends through the given notifiers, sms or
+// email, when they are enabled. Without channels it sends through all of them.
+func (r *Reporter) Only(channels []string) *Reporter {
+	if len(channels) == 0 {
+		return r
+	}
+	config := r.config
+	config.SMS.Enabled = config.SMS.Enabled && slices.Contains(channels, "sms")
+	config.Email.Enabled = config.Email.Enabled && slices.Contains(channels, "email")
+	return &Reporter{config: config, only: channels}
+}
+
+// SendAlert sends a new listing through every notifier, with the photos
+// attached to the email.
+func (r *Reporter) SendAlert(newAdress string, prefix string, photos []string, logger *logger.Logger) {
 	body := prefix + " New adress found: " + newAdress
-	res, err := sendSMS(body)
-	if err != nil {
+	res, err := r.sendSMS(body)
+	if errors.Is(err, errNotifierDisabled) {
+		logger.Debug("SMS notifier disabled, skipping")
+	} else if err != nil {
+		metrics.ObserveAlert("sms", err)
 		logger.Error("Error sending SMS", "error", err)
 	} else {
+		metrics.ObserveAlert("sms", nil)
 		logger.Info("SMS sent", "response", res)
 	}
 
-	res, err = sendEmail(body, body)
-	if err != nil {
+	res, err = r.sendEmail(body, body, photos...)
+	if errors.Is(err, errNotifierDisabled) {
+		logger.Debug("Email notifier disabled, skipping")
+	} else if err != nil {
+		metrics.ObserveAlert("email", err)
 		logger.Error("Error sending email", "error", err)
 	} else {
+		metrics.ObserveAlert("email", nil)
 		logger.Info("Email sent", "response", res)
 	}
 }
 
-func SendAlertForMultipleResults(results string, prefix string, logger *logger.Logger) {
-	body := prefix + " New adress found: \n" + results
+// Result is one of the listings of an alert with several.
+type Result struct {
+	Address string
+	// The line in the email, with what is known about the listing
+	Text string
+}
+
+// Longest SMS sent for several results, longer ones are cut off
+const maxSMSLength = 320
+
+// SendAlertForMultipleResults sends one alert for several listings: an email
+// with a line per listing, and an SMS with their addresses only when the sms
+// notifier was chosen with Only. By default several listings are emailed.
+func (r *Reporter) SendAlertForMultipleResults(results []Result, prefix string, photos []string, logger *logger.Logger) {
+	if slices.Contains(r.only, "sms") {
+		res, err := r.sendSMS(smsSummary(prefix, results))
+		if errors.Is(err, errNotifierDisabled) {
+			logger.Debug("SMS notifier disabled, skipping")
+		} else if err != nil {
+			metrics.ObserveAlert("sms", err)
+			logger.Error("Error sending SMS", "error", err)
+		} else {
+			metrics.ObserveAlert("sms", nil)
+			logger.Info("SMS sent", "response", res)
+		}
+	}
+
+	lines := make([]string, len(results))
+	for i, result := range results {
+		lines[i] = result.Text
+	}
+	body := prefix + "\n New adress found: \n" + strings.Join(lines, "\n")
 	subject := prefix + " Multiple new results found!"
-	res, err := sendEmail(body, subject)
-	if err != nil {
+	res, err := r.sendEmail(body, subject, photos...)
+	if errors.Is(err, errNotifierDisabled) {
+		logger.Debug("Email notifier disabled, skipping")
+	} else if err != nil {
+		metrics.ObserveAlert("email", err)
 		logger.Error("Error sending email", "error", err)
 	} else {
+		metrics.ObserveAlert("email", nil)
 		logger.Info("Email sent", "response", res)
 	}
 }
 
-func sendSMS(body string) (string, error) {
-	config, err := config.LoadConfig()
-	if err != nil {
-		return "", fmt.Errorf("Failed to load config: %v", err)
+// smsSummary is e.g. "REBO: 3 new results: Oudegracht 12; Biltstraat 5; ...",
+// with as many addresses as fit.
+func smsSummary(prefix string, results []Result) string {
+	summary := fmt.Sprintf("%s %d new results: ", prefix, len(results))
+	for i, result := range results {
+		more := ""
+		if i < len(results)-1 {
+			more = fmt.Sprintf(" (+%d more)", len(results)-i-1)
+		}
+		next := result.Address
+		if i > 0 {
+			next = "; " + next
+		}
+		if len(summary)+len(next)+len(more) > maxSMSLength {
+			return summary + fmt.Sprintf(" (+%d more)", len(results)-i)
+		}
+		summary += next
+	}
+	return summary
+}
+
+// SendTest sends body through every enabled notifier and returns the result
+// per notifier, nil on success.
+func (r *Reporter) SendTest(body string) map[string]error {
+	results := make(map[string]error)
+	if _, err := r.sendSMS(body); !errors.Is(err, errNotifierDisabled) {
+		results["sms"] = err
+	}
+	if _, err := r.sendEmail(body, "Test alert"); !errors.Is(err, errNotifierDisabled) {
+		results["email"] = err
+	}
+	return results
+}
+
+func (r *Reporter) sendSMS(body string) (string, error) {
+	sms := r.config.SMS
+	if !sms.Enabled {
+		return "", errNotifierDisabled
 	}
-	// send an sms with twilio.
-	// add the prefix to the newAdress
+	return deliverSMS(sms, body)
+}
+
+// deliverSMS sends body to every number with twilio, replaced in tests.
+var deliverSMS = func(sms config.SMSConfig, body string) (string, error) {
 	client := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: config.TWILIO_SID,
-		Password: config.TWILIO_TOKEN,
+		Username: sms.AccountSID,
+		Password: sms.AuthToken.Value(),
 	})
 
-	params := &openapi.CreateMessageParams{}
-	params.SetTo(config.YOUR_PHONE_NUMBER)
-	params.SetFrom(config.TWILIO_PHONE_NUMBER)
-	params.SetBody(body)
+	var sids []string
+	for _, to := range sms.To {
+		params := &openapi.CreateMessageParams{}
+		params.SetTo(to)
+		params.SetFrom(sms.From)
+		params.SetBody(body)
 
-	resp, err := client.Api.CreateMessage(params)
-	if err != nil {
-		return "", fmt.Errorf("Error sending SMS: %v", err)
+		resp, err := client.Api.CreateMessage(params)
+		if err != nil {
+			return "", fmt.Errorf("Error sending SMS: %v", err)
+		}
+		sids = append(sids, *resp.Sid)
 	}
 
-	return *resp.Sid, nil
+	return fmt.Sprint(sids), nil
 }
 
-func sendEmail(body string, subject string) (string, error) {
-	config, err := config.LoadConfig()
-	if err != nil {
-		return "", fmt.Errorf("Failed to load config: %v", err)
+func (r *Reporter) sendEmail(body string, subject string, attachments ...string) (string, error) {
+	cfg := r.config.Email
+	if !cfg.Enabled {
+		return "", errNotifierDisabled
 	}
 
 	e := email.NewEmail()
-	e.From = config.FROM_EMAIL
-	e.To = []string{config.TO_EMAIL}
+	e.From = cfg.From
+	e.To = cfg.To
 	e.Subject = subject
 	e.Text = []byte(body)
+	// A photo that can't be read shouldn't stop the alert
+	for _, path := range attachments {
+		e.AttachFile(path)
+	}
 
 	// Set headers to mark the email as important
 	e.Headers.Add("X-Priority", "1")    // 1 = High, 3 = Normal, 5 = Low
 	e.Headers.Add("Importance", "High") // High, Normal, Low
 
-	fmt.Println(config.SMTP_PASSWORD)
-
-	err = e.Send(fmt.Sprintf("%s:%d", config.SMTP_SERVER, config.SMTP_PORT), smtp.PlainAuth("", config.SMTP_USERNAME, config.SMTP_PASSWORD, config.SMTP_SERVER))
+	err := deliverEmail(cfg, e)
 	if err != nil {
 		return "", fmt.Errorf("Error sending email: %v", err)
 	} else {
 		return fmt.Sprintf("Email sent successfully"), nil
 	}
 }
+
+// deliverEmail sends e through the SMTP server of cfg, replaced in tests.
+var deliverEmail = func(cfg config.EmailConfig, e *email.Email) error {
+	return e.Send(fmt.Sprintf("%s:%d", cfg.Server, cfg.Port), smtp.PlainAuth("", cfg.Username, cfg.Password.Value(), cfg.Server))
+}
@@ -0,0 +1,108 @@
+package reporting
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jordan-wright/email"
+
+	"huurwoning/config"
+	"huurwoning/logger"
+)
+
+// capture replaces the delivery of SMS and email for the duration of the test.
+func capture(t *testing.T) (sms *[]string, emails *[]*email.Email) {
+	t.Helper()
+	sms, emails = &[]string{}, &[]*email.Email{}
+	deliverSMSBefore, deliverEmailBefore := deliverSMS, deliverEmail
+	deliverSMS = func(_ config.SMSConfig, body string) (string, error) {
+		*sms = append(*sms, body)
+		return "ok", nil
+	}
+	deliverEmail = func(_ config.EmailConfig, e *email.Email) error {
+		*emails = append(*emails, e)
+		return nil
+	}
+	t.Cleanup(func() { deliverSMS, deliverEmail = deliverSMSBefore, deliverEmailBefore })
+	return sms, emails
+}
+
+func testLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+	gl, err := logger.NewGlobalLogger(config.LoggingConfig{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gl.Logger("TEST")
+}
+
+func TestSendAlertForMultipleResults(t *testing.T) {
+	notifiers := config.NotifiersConfig{
+		SMS:   config.SMSConfig{Enabled: true, To: []string{"+31600000000"}},
+		Email: config.EmailConfig{Enabled: true, To: []string{"me@example.com"}},
+	}
+	results := []Result{
+		{Address: "Oudegracht 12, Utrecht", Text: "Oudegracht 12, Utrecht (EUR 1200)"},
+		{Address: "Biltstraat 5, Utrecht", Text: "Biltstraat 5, Utrecht (EUR 1100)"},
+	}
+
+	tests := []struct {
+		name       string
+		channels   []string
+		wantSMS    int
+		wantEmails int
+	}{
+		{name: "default", wantEmails: 1},
+		{name: "sms only", channels: []string{"sms"}, wantSMS: 1},
+		{name: "sms and email", channels: []string{"sms", "email"}, wantSMS: 1, wantEmails: 1},
+		{name: "email only", channels: []string{"email"}, wantEmails: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sms, emails := capture(t)
+			New(notifiers).Only(tt.channels).SendAlertForMultipleResults(results, "REBO:", nil, testLogger(t))
+
+			if len(*sms) != tt.wantSMS || len(*emails) != tt.wantEmails {
+				t.Fatalf("sent %d SMS and %d emails, want %d and %d", len(*sms), len(*emails), tt.wantSMS, tt.wantEmails)
+			}
+			for _, body := range *sms {
+				for _, want := range []string{"REBO:", "2 new results", "Oudegracht 12, Utrecht", "Biltstraat 5, Utrecht"} {
+					if !strings.Contains(body, want) {
+						t.Errorf("SMS %q does not contain %q", body, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSmsSummary(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	tests := []struct {
+		name    string
+		results []Result
+		want    string
+	}{
+		{
+			name:    "all fit",
+			results: []Result{{Address: "Oudegracht 12"}, {Address: "Biltstraat 5"}},
+			want:    "REBO: 2 new results: Oudegracht 12; Biltstraat 5",
+		},
+		{
+			name:    "cut off",
+			results: []Result{{Address: long}, {Address: long}, {Address: "Biltstraat 5"}},
+			want:    "REBO: 3 new results: " + long + " (+2 more)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := smsSummary("REBO:", tt.results)
+			if got != tt.want {
+				t.Errorf("smsSummary() = %q, want %q", got, tt.want)
+			}
+			if len(got) > maxSMSLength {
+				t.Errorf("smsSummary() is %d long, want at most %d", len(got), maxSMSLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOp && t.text == op
+}
+
+// or := and ("||" and)*
+func (p *parser) or() (func(*Listing) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(x *Listing) bool { return l(x) || right(x) }
+	}
+	return left, nil
+}
+
+// and := unary ("&&" unary)*
+func (p *parser) and() (func(*Listing) bool, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(x *Listing) bool { return l(x) && right(x) }
+	}
+	return left, nil
+}
+
+// unary := "!" unary | "(" or ")" | "true" | "false" | comparison
+func (p *parser) unary() (func(*Listing) bool, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenOp && t.text == "!":
+		p.next()
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(x *Listing) bool { return !inner(x) }, nil
+
+	case t.kind == tokenLParen:
+		p.next()
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if close := p.next(); close.kind != tokenRParen {
+			return nil, &Error{Col: close.col, Msg: fmt.Sprintf("expected ) to close the ( at column %d, got %s", t.col, close)}
+		}
+		return inner, nil
+
+	case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+		p.next()
+		value := t.text == "true"
+		return func(*Listing) bool { return value }, nil
+	}
+	return p.comparison()
+}
+
+// operand is a field or a literal, with a getter of its value.
+type operand struct {
+	typ    valueType
+	number func(*Listing) float64
+	text   func(*Listing) string
+	tok    token
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return operand{typ: typeNumber, number: func(*Listing) float64 { return t.num }, tok: t}, nil
+	case tokenString:
+		return operand{typ: typeString, text: func(*Listing) string { return t.text }, tok: t}, nil
+	case tokenIdent:
+		f, ok := fields[t.text]
+		if !ok {
+			return operand{}, &Error{Col: t.col, Msg: fmt.Sprintf("unknown field %q, use one of %s", t.text, strings.Join(Fields(), ", "))}
+		}
+		return operand{typ: f.typ, number: f.number, text: f.text, tok: t}, nil
+	}
+	return operand{}, &Error{Col: t.col, Msg: fmt.Sprintf("expected a field, number or text, got %s", t)}
+}
+
+// comparison := operand ("==" | "!=" | "<" | "<=" | ">" | ">=" | "contains") operand
+func (p *parser) comparison() (func(*Listing) bool, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.next()
+	valid := op.kind == tokenOp && op.text != "&&" && op.text != "||" && op.text != "!" ||
+		op.kind == tokenIdent && op.text == "contains"
+	if !valid {
+		return nil, &Error{Col: op.col, Msg: fmt.Sprintf("expected a comparison like == or <= after %s, got %s", left.tok, op)}
+	}
+
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ != right.typ {
+		return nil, &Error{Col: op.col, Msg: fmt.Sprintf("can't compare %s %s with %s %s", left.typ, left.tok, right.typ, right.tok)}
+	}
+
+	if left.typ == typeString {
+		l, r := left.text, right.text
+		switch op.text {
+		case "==":
+			return func(x *Listing) bool { return strings.EqualFold(l(x), r(x)) }, nil
+		case "!=":
+			return func(x *Listing) bool { return !strings.EqualFold(l(x), r(x)) }, nil
+		case "contains":
+			return func(x *Listing) bool { return strings.Contains(strings.ToLower(l(x)), strings.ToLower(r(x))) }, nil
+		}
+		return nil, &Error{Col: op.col, Msg: fmt.Sprintf("%s only works on numbers, text supports ==, != and contains", op.text)}
+	}
+
+	l, r := left.number, right.number
+	switch op.text {
+	case "==":
+		return func(x *Listing) bool { return l(x) == r(x) }, nil
+	case "!=":
+		return func(x *Listing) bool { return l(x) != r(x) }, nil
+	case "<":
+		return func(x *Listing) bool { return l(x) < r(x) }, nil
+	case "<=":
+		return func(x *Listing) bool { return l(x) <= r(x) }, nil
+	case ">":
+		return func(x *Listing) bool { return l(x) > r(x) }, nil
+	case ">=":
+		return func(x *Listing) bool { return l(x) >= r(x) }, nil
+	}
+	return nil, &Error{Col: op.col, Msg: "contains only works on text"}
+}
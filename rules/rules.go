@@ -0,0 +1,124 @@
+// Package rules evaluates conditions written as expressions against a listing,
+// e.g. `price <= 1600 && area >= 60 && city == "Utrecht"`.
+//
+// Expressions compare fields with numbers or strings using == != < <= > >=
+// and contains, and combine comparisons with && || ! and parentheses. String
+// comparisons ignore case. Fields that aren't known are 0 or "".
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Listing is what an expression is evaluated against.
+type Listing struct {
+	Source        string
+	Address       string
+	Street        string
+	Postcode      string
+	City          string
+	Neighbourhood string
+	Price         int
+	Area          int
+	Rooms         int
+	// Between 0 and 1
+	Score float64
+	// Shortest commute in minutes
+	Commute float64
+	// Days until the listing is available, 0 when it is available now
+	AvailableDays float64
+}
+
+type valueType int
+
+const (
+	typeNumber valueType = iota
+	typeString
+)
+
+func (t valueType) String() string {
+	if t == typeString {
+		return "text"
+	}
+	return "number"
+}
+
+type field struct {
+	typ    valueType
+	number func(l *Listing) float64
+	text   func(l *Listing) string
+}
+
+var fields = map[string]field{
+	"source":        {typ: typeString, text: func(l *Listing) string { return l.Source }},
+	"address":       {typ: typeString, text: func(l *Listing) string { return l.Address }},
+	"street":        {typ: typeString, text: func(l *Listing) string { return l.Street }},
+	"postcode":      {typ: typeString, text: func(l *Listing) string { return l.Postcode }},
+	"city":          {typ: typeString, text: func(l *Listing) string { return l.City }},
+	"neighbourhood": {typ: typeString, text: func(l *Listing) string { return l.Neighbourhood }},
+	"price":         {typ: typeNumber, number: func(l *Listing) float64 { return float64(l.Price) }},
+	"area":          {typ: typeNumber, number: func(l *Listing) float64 { return float64(l.Area) }},
+	"rooms":         {typ: typeNumber, number: func(l *Listing) float64 { return float64(l.Rooms) }},
+	"price_per_m2": {typ: typeNumber, number: func(l *Listing) float64 {
+		if l.Area == 0 {
+			return 0
+		}
+		return float64(l.Price) / float64(l.Area)
+	}},
+	"score":          {typ: typeNumber, number: func(l *Listing) float64 { return l.Score }},
+	"commute":        {typ: typeNumber, number: func(l *Listing) float64 { return l.Commute }},
+	"available_days": {typ: typeNumber, number: func(l *Listing) float64 { return l.AvailableDays }},
+}
+
+// Fields returns the names of the fields expressions can use.
+func Fields() []string {
+	return slices.Sorted(maps.Keys(fields))
+}
+
+// Error is a problem in an expression, at a 1-based column.
+type Error struct {
+	Col int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Col, e.Msg)
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	eval func(l *Listing) bool
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match reports whether the listing satisfies the expression.
+func (e *Expr) Match(l Listing) bool {
+	return e.eval(&l)
+}
+
+// Compile parses and type checks an expression.
+func Compile(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &Error{Col: 1, Msg: "empty expression"}
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	eval, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{Col: t.col, Msg: fmt.Sprintf("unexpected %s, expected && or ||", t)}
+	}
+	return &Expr{src: src, eval: eval}, nil
+}
@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	listing := Listing{
+		Source:  "REBO",
+		Address: "Oudegracht 12, 3511AB Utrecht",
+		City:    "Utrecht",
+		Price:   1500,
+		Area:    60,
+		Rooms:   3,
+		Score:   0.75,
+		Commute: 14,
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`price <= 1600`, true},
+		{`price < 1500`, false},
+		{`price == 1500 && area >= 60`, true},
+		{`price > 2000 || rooms == 3`, true},
+		{`!(price > 2000 || rooms == 3)`, false},
+		{`city == "utrecht"`, true},
+		{`city != "Amersfoort"`, true},
+		{`address contains "oudegracht"`, true},
+		{`address contains "Biltstraat"`, false},
+		{`price_per_m2 == 25`, true},
+		{`score >= 0.7 && commute < 15`, true},
+		{`neighbourhood == ""`, true},
+		{`true && !false`, true},
+		// && binds tighter than ||
+		{`false && false || true`, true},
+		{`false && (false || true)`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Match(listing); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricePerM2WithoutArea(t *testing.T) {
+	e, err := Compile(`price_per_m2 == 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Match(Listing{Price: 1500}) {
+		t.Error("price_per_m2 of a listing without area is not 0")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		col  int
+	}{
+		{``, 1},
+		{`size > 3`, 1},
+		{`price >`, 8},
+		{`price 1500`, 7},
+		{`price == "1500"`, 7},
+		{`city < "Utrecht"`, 6},
+		{`price contains 15`, 7},
+		{`(price > 3`, 11},
+		{`price > 3 rooms`, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("Compile() error = %v, want an *Error", err)
+			}
+			if e.Col != tt.col {
+				t.Errorf("error at column %d, want %d: %v", e.Col, tt.col, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package scoring
+
+import (
+	"slices"
+	"time"
+
+	"huurwoning/commute"
+	"huurwoning/config"
+	"huurwoning/db"
+	"huurwoning/geo"
+	"huurwoning/rules"
+)
+
+// Priorities of alerts, from rules
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+var priorityRank = map[string]int{PriorityHigh: 2, PriorityNormal: 1, PriorityLow: 0}
+
+// ComparePriority orders a before b when it has a higher priority.
+func ComparePriority(a, b string) int {
+	return priorityRank[b] - priorityRank[a]
+}
+
+// Evaluator decides which profiles a listing belongs to, scores it and
+// applies the rules of the profiles.
+type Evaluator struct {
+	cfg    *config.Config
+	router commute.Router
+}
+
+// NewEvaluator returns an evaluator for cfg. When the configured router
+// can't be used the commute is estimated in a straight line, and the error
+// is returned next to the evaluator to be logged.
+func NewEvaluator(cfg *config.Config) (*Evaluator, error) {
+	e := &Evaluator{cfg: cfg}
+	var err error
+	e.router, err = commute.New(cfg)
+	if err != nil {
+		e.router = commute.StraightLine{Factor: cfg.Routing.Factor, Speeds: cfg.Routing.Speeds}
+	}
+	return e, err
+}
+
+// Evaluation is what is known about a listing after evaluating it.
+type Evaluation struct {
+	// Profiles the listing belongs to
+	Profiles []string
+	// Travel times to the destinations of those profiles
+	Commute []commute.Time
+	// Notifiers to alert through, nil for all
+	Channels []string
+	Priority string
+	Result
+}
+
+// Evaluate matches a property against the profiles, estimates its commute,
+// scores it and applies the first matching rule of every profile it belongs
+// to. A listing without a location isn't held to the commute limits. Without
+// profiles every listing matches.
+func (e *Evaluator) Evaluate(p db.Property) (Evaluation, bool, error) {
+	ev := Evaluation{Priority: PriorityNormal}
+	location := geo.PropertyLocation(p)
+
+	var err error
+	var matched []config.ProfileConfig
+	for _, profile := range e.cfg.Profiles {
+		if !profile.Match(p.Source, p.Address, location) {
+			continue
+		}
+		if len(profile.Commute) > 0 && location != nil {
+			times, routeErr := commute.Times(e.router, *location, profile.Commute)
+			if routeErr != nil {
+				err = routeErr
+			} else if !commute.Within(times) {
+				continue
+			}
+			ev.Commute = append(ev.Commute, times...)
+		}
+		matched = append(matched, profile)
+	}
+
+	ev.Result = Score(e.cfg.Scoring, Input{
+		Source:        p.Source,
+		Price:         p.Price,
+		Area:          p.Area,
+		Rooms:         p.Rooms,
+		AvailableFrom: p.AvailableFrom,
+		Commute:       ev.Commute,
+	}, time.Now())
+
+	if len(e.cfg.Profiles) == 0 {
+		return ev, true, err
+	}
+
+	listing := ruleListing(p, ev)
+	ev.Priority = PriorityLow
+	allChannels := false
+	for _, profile := range matched {
+		rule := profile.Rule(listing)
+		if rule != nil && rule.Ignore {
+			continue
+		}
+		ev.Profiles = append(ev.Profiles, profile.Name)
+
+		priority := PriorityNormal
+		if rule != nil && rule.Priority != "" {
+			priority = rule.Priority
+		}
+		if ComparePriority(priority, ev.Priority) < 0 {
+			ev.Priority = priority
+		}
+
+		if rule == nil || len(rule.Channels) == 0 {
+			allChannels = true
+		}
+		for _, channel := range channels(rule) {
+			if !slices.Contains(ev.Channels, channel) {
+				ev.Channels = append(ev.Channels, channel)
+			}
+		}
+	}
+	if allChannels {
+		ev.Channels = nil
+	}
+	return ev, len(ev.Profiles) > 0, err
+}
+
+func channels(rule *config.RuleConfig) []string {
+	if rule == nil {
+		return nil
+	}
+	return rule.Channels
+}
+
+// ruleListing is what the rules see of a property.
+func ruleListing(p db.Property, ev Evaluation) rules.Listing {
+	l := rules.Listing{
+		Source:        p.Source,
+		Address:       p.Address,
+		Street:        p.Parts.Street,
+		Postcode:      p.Parts.Postcode,
+		City:          p.Parts.City,
+		Neighbourhood: p.Neighbourhood,
+		Price:         p.Price,
+		Area:          p.Area,
+		Rooms:         p.Rooms,
+		Score:         ev.Score,
+	}
+	if len(ev.Commute) > 0 {
+		l.Commute = commute.Shortest(ev.Commute).Minutes()
+	}
+	if days := time.Until(p.AvailableFrom).Hours() / 24; !p.AvailableFrom.IsZero() && days > 0 {
+		l.AvailableDays = days
+	}
+	return l
+}
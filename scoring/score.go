@@ -0,0 +1,115 @@
+package scoring
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"huurwoning/commute"
+	"huurwoning/config"
+)
+
+// Input is what a listing is scored on, zero values are unknown.
+type Input struct {
+	Source        string
+	Price         int
+	Area          int
+	Rooms         int
+	AvailableFrom time.Time
+	Commute       []commute.Time
+}
+
+// Part is one scored aspect of a listing.
+type Part struct {
+	// What was scored, e.g. "€18/m²" or "3 rooms"
+	Label  string
+	Score  float64
+	Weight float64
+}
+
+// Result is the score of a listing with its parts, the explanation.
+type Result struct {
+	Score float64
+	Parts []Part
+}
+
+// Scored reports whether the listing was scored, which it isn't when every weight is 0.
+func (r Result) Scored() bool {
+	return len(r.Parts) > 0
+}
+
+// String explains the score, e.g. "0.82: €18/m² 0.90, 3 rooms 1.00, REBO 1.00".
+func (r Result) String() string {
+	if !r.Scored() {
+		return "not scored"
+	}
+	parts := make([]string, len(r.Parts))
+	for i, p := range r.Parts {
+		parts[i] = fmt.Sprintf("%s %.2f", p.Label, p.Score)
+	}
+	return fmt.Sprintf("%.2f: %s", r.Score, strings.Join(parts, ", "))
+}
+
+// unknown is the score of a part that isn't known, neither good nor bad.
+const unknown = 0.5
+
+// Score is the weighted average of the parts of a listing. Parts that aren't
+// known score 0.5, so a listing isn't ranked high on the little known about it.
+func Score(cfg config.ScoringConfig, in Input, now time.Time) Result {
+	var r Result
+	add := func(weight float64, label string, score float64) {
+		if weight > 0 {
+			r.Parts = append(r.Parts, Part{Label: label, Score: score, Weight: weight})
+		}
+	}
+
+	if in.Price > 0 && in.Area > 0 {
+		perM2 := float64(in.Price) / float64(in.Area)
+		add(cfg.Weights.PricePerM2, fmt.Sprintf("€%.0f/m²", perM2), cost(perM2, cfg.PricePerM2))
+	} else {
+		add(cfg.Weights.PricePerM2, "price/m² unknown", unknown)
+	}
+
+	if in.Rooms > 0 {
+		add(cfg.Weights.Rooms, fmt.Sprintf("%d rooms", in.Rooms), min(float64(in.Rooms)/float64(cfg.Rooms), 1))
+	} else {
+		add(cfg.Weights.Rooms, "rooms unknown", unknown)
+	}
+
+	if len(in.Commute) > 0 {
+		shortest := commute.Shortest(in.Commute)
+		add(cfg.Weights.Distance, fmt.Sprintf("%.0f min away", shortest.Minutes()), cost(shortest.Minutes(), float64(cfg.CommuteMinutes)))
+	} else {
+		add(cfg.Weights.Distance, "distance unknown", unknown)
+	}
+
+	switch days := in.AvailableFrom.Sub(now).Hours() / 24; {
+	case in.AvailableFrom.IsZero():
+		add(cfg.Weights.Availability, "availability unknown", unknown)
+	case days <= 0:
+		add(cfg.Weights.Availability, "available now", 1)
+	default:
+		add(cfg.Weights.Availability, "available "+in.AvailableFrom.Format("2 Jan"), cost(days, float64(cfg.AvailableWithinDays)))
+	}
+
+	if reliability, ok := cfg.Sources[in.Source]; ok {
+		add(cfg.Weights.Source, in.Source, reliability)
+	} else {
+		add(cfg.Weights.Source, in.Source+" unrated", unknown)
+	}
+
+	var total, weights float64
+	for _, p := range r.Parts {
+		total += p.Score * p.Weight
+		weights += p.Weight
+	}
+	if weights > 0 {
+		r.Score = total / weights
+	}
+	return r
+}
+
+// cost scores a value where less is better: 1 up to target, 0 from twice the target.
+func cost(value, target float64) float64 {
+	return max(0, min(1, 2-value/target))
+}
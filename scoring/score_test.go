@@ -0,0 +1,115 @@
+package scoring
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"huurwoning/commute"
+	"huurwoning/config"
+)
+
+func TestCost(t *testing.T) {
+	tests := []struct {
+		value, target, want float64
+	}{
+		{0, 20, 1},
+		{20, 20, 1},
+		{30, 20, 0.5},
+		{40, 20, 0},
+		{60, 20, 0},
+	}
+	for _, tt := range tests {
+		if got := cost(tt.value, tt.target); got != tt.want {
+			t.Errorf("cost(%v, %v) = %v, want %v", tt.value, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cfg := config.ScoringConfig{
+		Weights:             config.ScoreWeights{PricePerM2: 1, Rooms: 1, Distance: 1, Availability: 1, Source: 1},
+		PricePerM2:          20,
+		Rooms:               3,
+		CommuteMinutes:      20,
+		AvailableWithinDays: 30,
+		Sources:             map[string]float64{"REBO": 0.8},
+	}
+
+	tests := []struct {
+		name   string
+		cfg    func(c *config.ScoringConfig)
+		in     Input
+		want   float64
+		labels []string
+	}{
+		{
+			name: "everything known",
+			in: Input{
+				Source: "REBO", Price: 1500, Area: 60, Rooms: 3,
+				AvailableFrom: now.AddDate(0, 0, 45),
+				Commute:       []commute.Time{{Duration: 30 * time.Minute}, {Duration: 14 * time.Minute}},
+			},
+			// €25/m² 0.75, 3 rooms 1, 14 min 1, 45 days 0.5, REBO 0.8
+			want:   (0.75 + 1 + 1 + 0.5 + 0.8) / 5,
+			labels: []string{"€25/m²", "3 rooms", "14 min away", "available 15 Apr", "REBO"},
+		},
+		{
+			name:   "nothing known scores 0.5",
+			in:     Input{Source: "BEUMER"},
+			want:   0.5,
+			labels: []string{"price/m² unknown", "rooms unknown", "distance unknown", "availability unknown", "BEUMER unrated"},
+		},
+		{
+			name:   "available now and more rooms than needed",
+			in:     Input{Source: "REBO", Rooms: 5, AvailableFrom: now.AddDate(0, 0, -1)},
+			cfg:    func(c *config.ScoringConfig) { c.Weights = config.ScoreWeights{Rooms: 1, Availability: 1} },
+			want:   1,
+			labels: []string{"5 rooms", "available now"},
+		},
+		{
+			name:   "weights count",
+			in:     Input{Source: "REBO", Price: 2400, Area: 60, Rooms: 3},
+			cfg:    func(c *config.ScoringConfig) { c.Weights = config.ScoreWeights{PricePerM2: 3, Rooms: 1} },
+			want:   (0*3 + 1*1) / 4.0,
+			labels: []string{"€40/m²", "3 rooms"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cfg
+			if tt.cfg != nil {
+				tt.cfg(&c)
+			}
+			r := Score(c, tt.in, now)
+			if math.Abs(r.Score-tt.want) > 1e-9 {
+				t.Errorf("Score = %v, want %v (%s)", r.Score, tt.want, r)
+			}
+			var labels []string
+			for _, p := range r.Parts {
+				labels = append(labels, p.Label)
+			}
+			if !slices.Equal(labels, tt.labels) {
+				t.Errorf("labels = %q, want %q", labels, tt.labels)
+			}
+		})
+	}
+}
+
+func TestScoreWithoutWeights(t *testing.T) {
+	r := Score(config.ScoringConfig{}, Input{Source: "REBO", Price: 1500, Area: 60}, time.Now())
+	if r.Scored() || r.Score != 0 || r.String() != "not scored" {
+		t.Errorf("Score without weights = %v, want not scored", r)
+	}
+}
+
+func TestComparePriority(t *testing.T) {
+	priorities := []string{PriorityLow, PriorityHigh, "", PriorityNormal}
+	slices.SortStableFunc(priorities, ComparePriority)
+	want := []string{PriorityHigh, PriorityNormal, PriorityLow, ""}
+	if !slices.Equal(priorities, want) {
+		t.Errorf("sorted priorities = %q, want %q", priorities, want)
+	}
+}
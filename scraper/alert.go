@@ -0,0 +1,74 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+
+	"huurwoning/db"
+	"huurwoning/reporting"
+	"huurwoning/scoring"
+)
+
+// alert is a new listing that passed the filters, with what was worked out
+// about it on the way.
+type alert struct {
+	listing db.Listing
+	scoring.Evaluation
+}
+
+// text is the line of the alert message, e.g.
+// "Oudegracht 12 (work 14 min by bike), score 0.82: €18/m² 0.90, 14 min away 1.00".
+func (a alert) text() string {
+	text := a.listing.Address
+	if len(a.Commute) > 0 {
+		times := make([]string, len(a.Commute))
+		for i, t := range a.Commute {
+			times[i] = t.String()
+		}
+		text += " (" + strings.Join(times, ", ") + ")"
+	}
+	if a.Scored() {
+		text += ", score " + a.String()
+	}
+	if a.Priority == scoring.PriorityHigh {
+		text = "[high] " + text
+	}
+	return text
+}
+
+// sendAlerts sends the alerts through the channels their rules chose. High
+// priority alerts are sent on their own, the others together per set of channels.
+func (s *Scraper) sendAlerts(alerts []alert) {
+	var groups [][]alert
+	index := make(map[string]int)
+	for _, a := range alerts {
+		if a.Priority == scoring.PriorityHigh {
+			groups = append(groups, []alert{a})
+			continue
+		}
+		key := strings.Join(a.Channels, ",")
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], a)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []alert{a})
+	}
+
+	for _, group := range groups {
+		reporter := s.reporter.Only(group[0].Channels)
+		if len(group) == 1 {
+			s.Logger.Warn(fmt.Sprintf("New result found %s", group[0].listing.Address), "priority", group[0].Priority)
+			reporter.SendAlert(group[0].text(), fmt.Sprintf("%s: ", s.name), s.photoFiles(group, s.photos.Attach), s.Logger)
+			continue
+		}
+
+		// Send one alert with all results
+		s.Logger.Warn(fmt.Sprintf("%d new results found.", len(group)))
+		results := make([]reporting.Result, len(group))
+		for i, a := range group {
+			results[i] = reporting.Result{Address: a.listing.Address, Text: a.text()}
+		}
+		reporter.SendAlertForMultipleResults(results, fmt.Sprintf("%s:", s.name), s.photoFiles(group, s.photos.Attach), s.Logger)
+	}
+}
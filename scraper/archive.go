@@ -0,0 +1,78 @@
+package scraper
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"time"
+
+	"huurwoning/db"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// MHTML snapshots include every image, so they take longer
+const archiveTimeout = 60 * time.Second
+
+var headPattern = regexp.MustCompile(`(?i)<head[^>]*>`)
+
+// archivePage keeps the detail page that is open in the tab for the listing.
+// A page that can't be archived is only logged.
+func (s *Scraper) archivePage(listing *db.Listing) {
+	ctx, cancel := context.WithTimeout(s.TabCtx, archiveTimeout)
+	defer cancel()
+
+	var outerHTML, mhtml string
+	actions := []chromedp.Action{
+		chromedp.Evaluate(`document.documentElement.outerHTML`, &outerHTML),
+	}
+	if s.archive.MHTML {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			mhtml, err = page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+			return err
+		}))
+	}
+	if err := chromedp.Run(ctx, actions...); err != nil {
+		s.Logger.Warn("Failed to archive detail page", "address", listing.Address, "error", err)
+		return
+	}
+
+	name, err := s.archiveStore.Save(s.name, listing.URL, time.Now(), archiveHTML(outerHTML, listing.URL), []byte(mhtml))
+	if err != nil {
+		s.Logger.Warn("Failed to save archive", "address", listing.Address, "error", err)
+		return
+	}
+	listing.Archive = name
+	s.Logger.Debug("Archived detail page", "address", listing.Address, "archive", name)
+}
+
+// archiveHTML makes the HTML of a page a document of its own, with relative
+// links pointing at the site it came from.
+func archiveHTML(doc, url string) []byte {
+	base := `<base href="` + html.EscapeString(url) + `">`
+	if loc := headPattern.FindStringIndex(doc); loc != nil {
+		doc = doc[:loc[1]] + base + doc[loc[1]:]
+	}
+	return []byte("<!DOCTYPE html>\n" + doc)
+}
+
+func (s *Scraper) pruneArchive() {
+	if s.archive.RetentionDays == 0 {
+		return
+	}
+	removed, err := s.archiveStore.Prune(time.Duration(s.archive.RetentionDays) * 24 * time.Hour)
+	if err != nil {
+		s.Logger.Warn("Failed to remove old archives", "error", err)
+	}
+	if len(removed) == 0 {
+		return
+	}
+	// Listings don't link to the removed pages anymore
+	if err := s.db.ClearArchives(removed); err != nil {
+		s.Logger.Warn("Failed to forget removed archives", "error", err)
+		return
+	}
+	s.Logger.Info("Removed old archives", "archives", len(removed))
+}
@@ -0,0 +1,217 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"huurwoning/browser"
+	"huurwoning/db"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	detailTimeout = 30 * time.Second
+	// Longer descriptions are cut off
+	maxDescription = 5000
+	maxPhotos      = 30
+)
+
+// detailJS reads a detail page: its text, the description and the photo
+// URLs. Empty selectors use defaults that work for most rental sites.
+func detailJS(description, photos string) string {
+	return fmt.Sprintf(`
+		(() => {
+			const find = (selector, fallback) => {
+				try { return document.querySelectorAll(selector || fallback); } catch (e) { return []; }
+			};
+			const text = (document.body.innerText || '').slice(0, 100000);
+
+			const descriptions = find(%q, '[class*="description" i], [class*="omschrijving" i], [id*="description" i], [id*="omschrijving" i]');
+			let description = descriptions.length ? descriptions[0].innerText : '';
+			if (!description) {
+				const meta = document.querySelector('meta[property="og:description"], meta[name="description"]');
+				description = meta ? meta.content : '';
+			}
+
+			const photos = [];
+			const og = document.querySelector('meta[property="og:image"]');
+			if (og && og.content) photos.push(og.content);
+			for (const el of find(%q, 'img')) {
+				let src = el.currentSrc || el.src || el.href || el.dataset.src || '';
+				if (el.tagName === 'IMG' && !%t && el.naturalWidth < 400 && !el.dataset.src) continue;
+				if (!src && el.dataset.src) src = el.dataset.src;
+				if (!src || src.startsWith('data:') || /logo|icon|\.svg/i.test(src)) continue;
+				photos.push(new URL(src, location.href).href);
+			}
+			return {text, description, photos: [...new Set(photos)].slice(0, %d)};
+		})()
+	`, description, photos, photos != "", maxPhotos)
+}
+
+type detailPage struct {
+	Text        string   `json:"text"`
+	Description string   `json:"description"`
+	Photos      []string `json:"photos"`
+}
+
+// enrich visits the detail page of new listings, up to the maximum per run,
+// to add what it finds and to archive the page. Only new listings are visited
+// to keep the traffic low, a failed visit leaves the listing as it is.
+func (s *Scraper) enrich(listings []*db.Listing) {
+	archive := s.archive.Enabled && !s.dryRun
+	if !s.details.Enabled && !archive {
+		return
+	}
+
+	visited := 0
+	for _, listing := range listings {
+		if listing.URL == "" {
+			continue
+		}
+		if visited == s.details.MaxPerRun {
+			s.Logger.Info("Reached the maximum of detail pages per run, the other new listings are stored without visiting them", "max", s.details.MaxPerRun)
+			break
+		}
+		visited++
+
+		if err := s.openDetailPage(listing.URL); err != nil {
+			s.Logger.Warn("Failed to open detail page", "address", listing.Address, "url", listing.URL, "error", err)
+			continue
+		}
+
+		if s.details.Enabled {
+			page, err := s.readDetailPage()
+			if err != nil {
+				s.Logger.Warn("Failed to read detail page", "address", listing.Address, "url", listing.URL, "error", err)
+			} else {
+				details := parseDetails(page, listing)
+				listing.Details = &details
+				s.Logger.Debug("Read detail page", "address", listing.Address, "photos", len(details.Photos))
+			}
+		}
+		if archive {
+			s.archivePage(listing)
+		}
+	}
+
+	if archive {
+		s.pruneArchive()
+	}
+}
+
+func (s *Scraper) openDetailPage(url string) error {
+	ctx, cancel := context.WithTimeout(s.TabCtx, detailTimeout)
+	defer cancel()
+
+	waitFor := s.details.WaitFor
+	if waitFor == "" {
+		waitFor = "body"
+	}
+	return chromedp.Run(ctx,
+		chromedp.Navigate(url),
+		browser.DismissConsent(s.consent, nil),
+		chromedp.WaitVisible(waitFor, chromedp.ByQuery),
+	)
+}
+
+func (s *Scraper) readDetailPage() (detailPage, error) {
+	ctx, cancel := context.WithTimeout(s.TabCtx, detailTimeout)
+	defer cancel()
+
+	var page detailPage
+	err := chromedp.Run(ctx, chromedp.Evaluate(detailJS(s.details.Description, s.details.Photos), &page))
+	return page, err
+}
+
+var (
+	energyLabelPattern  = regexp.MustCompile(`(?i)energ(?:ie|y)-?\s*label\s*:?\s*([A-G]\+{0,4})(?:\s|$|[^\w+])`)
+	serviceCostsPattern = regexp.MustCompile(`(?i)service\s*(?:kosten|costs|charges)\D{0,20}?(\d[\d.,]*)`)
+	depositPattern      = regexp.MustCompile(`(?i)(?:waarborgsom|borg|deposit)\D{0,20}?(\d[\d.,]*)`)
+	incomePattern       = regexp.MustCompile(`(?i)(?:inkomenseis|minimum\s*inkomen|income\s*requirement)\s*:?\s*([^\n]{1,120})`)
+	furnishingPattern   = regexp.MustCompile(`(?i)(?:interieur|oplevering|inrichting|furnishing)\s*:?\s*(\w+)`)
+	areaPattern         = regexp.MustCompile(`(?i)(?:woonoppervlakte|oppervlakte|living\s*area)\D{0,20}?(\d+)\s*m`)
+	roomsPattern        = regexp.MustCompile(`(?i)(?:aantal\s*kamers|kamers|rooms)\s*:?\s*(\d+)`)
+	availablePattern    = regexp.MustCompile(`(?i)(?:beschikbaar|available|ingangsdatum)\s*(?:per|vanaf|from)?\s*:?\s*([^\n]+)`)
+)
+
+// parseDetails reads the details from a detail page, and fills in the area,
+// rooms and availability of the listing when the overview didn't show them.
+func parseDetails(page detailPage, listing *db.Listing) db.Details {
+	text := page.Text
+	details := db.Details{
+		Description:       strings.TrimSpace(page.Description),
+		EnergyLabel:       strings.ToUpper(submatch(energyLabelPattern, text)),
+		ServiceCosts:      euros(submatch(serviceCostsPattern, text)),
+		Deposit:           euros(submatch(depositPattern, text)),
+		IncomeRequirement: strings.TrimSpace(submatch(incomePattern, text)),
+		Furnishing:        furnishing(text),
+	}
+	for _, url := range page.Photos {
+		details.Photos = append(details.Photos, db.Photo{URL: url})
+	}
+	if runes := []rune(details.Description); len(runes) > maxDescription {
+		details.Description = string(runes[:maxDescription]) + "…"
+	}
+
+	if listing.Area == 0 {
+		listing.Area, _ = strconv.Atoi(submatch(areaPattern, text))
+	}
+	if listing.Rooms == 0 {
+		listing.Rooms, _ = strconv.Atoi(submatch(roomsPattern, text))
+	}
+	if listing.AvailableFrom.IsZero() {
+		listing.AvailableFrom = ParseAvailable(submatch(availablePattern, text), time.Now())
+	}
+	return details
+}
+
+func submatch(pattern *regexp.Regexp, text string) string {
+	if m := pattern.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// euros reads an amount like 1.250, 1,250, 85,00 or 1.250,50 without cents.
+// A separator followed by at most two digits is the decimal one, the others
+// separate thousands.
+func euros(amount string) int {
+	amount = strings.TrimRight(amount, ".,")
+	if i := strings.LastIndexAny(amount, ".,"); i >= 0 && len(amount)-i-1 <= 2 {
+		amount = amount[:i]
+	}
+	n, _ := strconv.Atoi(strings.NewReplacer(".", "", ",", "").Replace(amount))
+	return n
+}
+
+var furnishingWords = []struct {
+	pattern    *regexp.Regexp
+	furnishing string
+}{
+	{regexp.MustCompile(`(?i)\b(?:gestoffeerd|upholstered|semi-furnished)\b`), db.Upholstered},
+	{regexp.MustCompile(`(?i)\b(?:kaal|ongemeubileerd|unfurnished|shell)\b`), db.Bare},
+	{regexp.MustCompile(`(?i)\b(?:gemeubileerd|gemeubeld|furnished)\b`), db.Furnished},
+}
+
+// furnishing prefers a labelled value like "Oplevering: gestoffeerd" over
+// the first furnishing word anywhere on the page.
+func furnishing(text string) string {
+	if labelled := submatch(furnishingPattern, text); labelled != "" {
+		for _, w := range furnishingWords {
+			if w.pattern.MatchString(labelled) {
+				return w.furnishing
+			}
+		}
+	}
+	for _, w := range furnishingWords {
+		if w.pattern.MatchString(text) {
+			return w.furnishing
+		}
+	}
+	return ""
+}
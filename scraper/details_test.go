@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"huurwoning/db"
+)
+
+func TestParseDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		page        detailPage
+		listing     db.Listing
+		want        db.Details
+		wantListing db.Listing
+	}{
+		{
+			name: "dutch labels",
+			page: detailPage{
+				Text: "Huurprijs € 1.450\nServicekosten: € 85,00\nWaarborgsom € 2.900\n" +
+					"Energielabel: A++\nInkomenseis: 3x de huurprijs\nOplevering: gestoffeerd\n" +
+					"Woonoppervlakte 72 m²\nAantal kamers 3\nBeschikbaar per: 01-12-2026",
+				Description: "  Licht appartement aan de gracht.  ",
+				Photos:      []string{"https://example.com/1.jpg", "https://example.com/2.jpg"},
+			},
+			want: db.Details{
+				Description:       "Licht appartement aan de gracht.",
+				EnergyLabel:       "A++",
+				ServiceCosts:      85,
+				Deposit:           2900,
+				IncomeRequirement: "3x de huurprijs",
+				Furnishing:        db.Upholstered,
+				Photos:            []db.Photo{{URL: "https://example.com/1.jpg"}, {URL: "https://example.com/2.jpg"}},
+			},
+			wantListing: db.Listing{Area: 72, Rooms: 3, AvailableFrom: time.Date(2026, 12, 1, 0, 0, 0, 0, time.Local)},
+		},
+		{
+			name: "english labels",
+			page: detailPage{
+				Text: "Service costs € 120\nDeposit: € 3.000\nEnergy label B\nIncome requirement: 4x the rent\n" +
+					"Furnishing: unfurnished\nLiving area: 55 m2\nRooms: 2",
+			},
+			want: db.Details{
+				EnergyLabel:       "B",
+				ServiceCosts:      120,
+				Deposit:           3000,
+				IncomeRequirement: "4x the rent",
+				Furnishing:        db.Bare,
+			},
+			wantListing: db.Listing{Area: 55, Rooms: 2},
+		},
+		{
+			name:        "the overview wins",
+			page:        detailPage{Text: "Woonoppervlakte 72 m²\nAantal kamers 3\nBeschikbaar per 01-12-2026"},
+			listing:     db.Listing{Area: 80, Rooms: 4, AvailableFrom: time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local)},
+			wantListing: db.Listing{Area: 80, Rooms: 4, AvailableFrom: time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local)},
+		},
+		{
+			name: "furnishing word without a label",
+			page: detailPage{Text: "Dit appartement wordt gemeubileerd verhuurd."},
+			want: db.Details{Furnishing: db.Furnished},
+		},
+		{
+			name: "labelled furnishing wins over other words",
+			page: detailPage{Text: "Ook gemeubileerd mogelijk.\nInterieur: kaal"},
+			want: db.Details{Furnishing: db.Bare},
+		},
+		{
+			name: "nothing found",
+			page: detailPage{Text: "Neem contact op voor meer informatie."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listing := tt.listing
+			got := parseDetails(tt.page, &listing)
+			if got.Description != tt.want.Description ||
+				got.EnergyLabel != tt.want.EnergyLabel ||
+				got.ServiceCosts != tt.want.ServiceCosts ||
+				got.Deposit != tt.want.Deposit ||
+				got.IncomeRequirement != tt.want.IncomeRequirement ||
+				got.Furnishing != tt.want.Furnishing ||
+				!slices.Equal(got.Photos, tt.want.Photos) {
+				t.Errorf("parseDetails() = %+v, want %+v", got, tt.want)
+			}
+			if listing.Area != tt.wantListing.Area || listing.Rooms != tt.wantListing.Rooms || !listing.AvailableFrom.Equal(tt.wantListing.AvailableFrom) {
+				t.Errorf("listing = area %d, rooms %d, available %v, want area %d, rooms %d, available %v",
+					listing.Area, listing.Rooms, listing.AvailableFrom,
+					tt.wantListing.Area, tt.wantListing.Rooms, tt.wantListing.AvailableFrom)
+			}
+		})
+	}
+}
+
+func TestParseDetailsTruncatesDescription(t *testing.T) {
+	var listing db.Listing
+	got := parseDetails(detailPage{Description: strings.Repeat("é", maxDescription+10)}, &listing)
+	if n := len([]rune(got.Description)); n != maxDescription+1 || !strings.HasSuffix(got.Description, "…") {
+		t.Errorf("description of %d runes, want %d ending in …", n, maxDescription+1)
+	}
+}
@@ -0,0 +1,45 @@
+package scraper
+
+import (
+	"fmt"
+
+	"huurwoning/db"
+)
+
+var changeSymbols = map[string]string{
+	db.EventNew:          "+",
+	db.EventReactivated:  "+",
+	db.EventPriceChanged: "~",
+	db.EventInactive:     "-",
+}
+
+// logDryRun logs the alerts and database changes a scrape would have made.
+func (s *Scraper) logDryRun(found []db.Listing, alerts []alert) {
+	for _, a := range alerts {
+		s.Logger.Info("Dry run, would alert " + a.text())
+	}
+
+	// Like UpdatePrevResults, nothing would be stored after an error
+	if s.HasError {
+		s.Logger.Info("Dry run, no database changes because the scrape failed")
+		return
+	}
+
+	changes, err := s.db.Diff(s.name, found)
+	if err != nil {
+		s.Logger.Error("Failed to compare results with the database", "error", err)
+		return
+	}
+	if len(changes) == 0 {
+		s.Logger.Info("Dry run, no database changes")
+		return
+	}
+	for _, c := range changes {
+		line := fmt.Sprintf("%s %s %s", changeSymbols[c.Type], c.Type, c.Address)
+		if c.Details != "" {
+			line += " (" + c.Details + ")"
+		}
+		s.Logger.Info("Dry run, would change " + line)
+	}
+	s.Logger.Info(fmt.Sprintf("Dry run, %d database changes not written", len(changes)))
+}
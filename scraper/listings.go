@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"huurwoning/db"
+)
+
+// ListingsJS returns a script that collects a listing for every element
+// matching selector: its text as address, and the link, price, area, rooms
+// and availability found in the surrounding card. Use it with
+// chromedp.Evaluate into a []ListingResult.
+func ListingsJS(selector string) string {
+	return fmt.Sprintf(`
+		Array.from(document.querySelectorAll(%q)).map(el => {
+			const card = el.closest('article, li, .card, [class*="card"]') || el.parentElement || el;
+			const link = el.closest('a[href]') || card.querySelector('a[href]');
+			const text = card.innerText || '';
+			const price = text.match(/€\s*(\d[\d.,]*)/);
+			const area = text.match(/(\d+)\s*m[²2]/);
+			const rooms = text.match(/(\d+)\s*kamers?\b/i) || text.match(/(\d+)\s*(?:slaapkamers?|bedrooms?|rooms?)\b/i);
+			const available = text.match(/(?:beschikbaar|available)\s*(?:per|vanaf|from)?\s*:?\s*([^\n,]+)/i);
+			return {
+				address: el.textContent,
+				url: link ? link.href : '',
+				price: price ? price[1] : '',
+				area: area ? parseInt(area[1], 10) : 0,
+				rooms: rooms ? parseInt(rooms[1], 10) : 0,
+				available: available ? available[1] : '',
+			};
+		})
+	`, selector)
+}
+
+// ListingResult is a listing as returned by ListingsJS.
+type ListingResult struct {
+	Address   string `json:"address"`
+	URL       string `json:"url"`
+	Price     string `json:"price"` // as written, e.g. 1.450 or 1,450.00
+	Area      int    `json:"area"`
+	Rooms     int    `json:"rooms"`
+	Available string `json:"available"`
+}
+
+// CleanListings trims the results of ListingsJS and drops the ones without an address.
+func CleanListings(results []ListingResult) []db.Listing {
+	listings := make([]db.Listing, 0, len(results))
+	for _, result := range results {
+		address := strings.TrimSpace(result.Address)
+		if address == "" {
+			continue
+		}
+		listings = append(listings, db.Listing{
+			Address:       address,
+			URL:           result.URL,
+			Price:         euros(result.Price),
+			Area:          result.Area,
+			Rooms:         result.Rooms,
+			AvailableFrom: ParseAvailable(result.Available, time.Now()),
+		})
+	}
+	return listings
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mrt": time.March, "maa": time.March, "mar": time.March,
+	"apr": time.April, "mei": time.May, "may": time.May, "jun": time.June, "jul": time.July,
+	"aug": time.August, "sep": time.September, "okt": time.October, "oct": time.October,
+	"nov": time.November, "dec": time.December,
+}
+
+var (
+	numericDate = regexp.MustCompile(`(\d{1,2})[-/.](\d{1,2})[-/.](\d{4})`)
+	writtenDate = regexp.MustCompile(`(\d{1,2})\s+([a-z]{3})[a-z]*\.?(?:\s+(\d{4}))?`)
+)
+
+// ParseAvailable reads an availability like "per direct", "01-12-2026" or
+// "1 december", zero when it isn't understood. A date without a year is taken
+// in the year closest to now, so "1 oktober" in late October is in the past.
+func ParseAvailable(text string, now time.Time) time.Time {
+	text = strings.ToLower(strings.TrimSpace(text))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	if strings.Contains(text, "direct") || strings.Contains(text, "per nu") || strings.HasPrefix(text, "nu") || strings.Contains(text, "immediately") {
+		return today
+	}
+	if m := numericDate.FindStringSubmatch(text); m != nil {
+		day, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		year, _ := strconv.Atoi(m[3])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return time.Time{}
+		}
+		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+	}
+	if m := writtenDate.FindStringSubmatch(text); m != nil {
+		month, ok := months[m[2]]
+		day, _ := strconv.Atoi(m[1])
+		if !ok || day < 1 || day > 31 {
+			return time.Time{}
+		}
+		if m[3] != "" {
+			year, _ := strconv.Atoi(m[3])
+			return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+		}
+		var closest time.Time
+		for year := now.Year() - 1; year <= now.Year()+1; year++ {
+			date := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+			if closest.IsZero() || date.Sub(today).Abs() < closest.Sub(today).Abs() {
+				closest = date
+			}
+		}
+		return closest
+	}
+	return time.Time{}
+}
@@ -0,0 +1,30 @@
+package scraper
+
+import "testing"
+
+func TestCleanListingsPrice(t *testing.T) {
+	tests := []struct {
+		price string
+		want  int
+	}{
+		{"1450", 1450},
+		{"1.450", 1450},
+		{"1,450", 1450},
+		{"1.450,00", 1450},
+		{"1,450.00", 1450},
+		{"1.450,5", 1450},
+		{"950,-", 950},
+		{"950,", 950},
+		{"1.234.567", 1234567},
+		{"85,00", 85},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.price, func(t *testing.T) {
+			listings := CleanListings([]ListingResult{{Address: "Oudegracht 12", Price: tt.price}})
+			if len(listings) != 1 || listings[0].Price != tt.want {
+				t.Errorf("price %q = %+v, want %d", tt.price, listings, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package scraper
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"huurwoning/address"
+	"huurwoning/browser"
+	"huurwoning/db"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Pagination strategies
+const (
+	// Navigate to a URL per page
+	PageURL = "url"
+	// Click a button that opens the next page
+	PageNext = "next"
+	// Scroll down for the next cards to load
+	PageScroll = "scroll"
+	// Click a button that adds the next cards below the others
+	PageLoadMore = "load_more"
+)
+
+const (
+	defaultMaxPages = 10
+	defaultPageWait = time.Second
+	// How long to wait for the listings of a page, a later page that doesn't
+	// show any in time is taken as past the last one
+	pageTimeout = 15 * time.Second
+)
+
+// Pagination describes how the results of a source are spread over pages.
+// Paginate reads page after page until a page is empty, a page has no new
+// listings, a page is shorter than PageSize or the maximum number of pages
+// is reached.
+type Pagination struct {
+	// PageURL, PageNext, PageScroll or PageLoadMore. PageScroll scrolls the
+	// first page until no more listings load, see browser.ScrollUntilStable.
+	Strategy string
+	// The first page, the URL of the source by default. For PageURL the URL
+	// of every page, with {page} for the page number.
+	URL string
+	// Selector of the button for PageNext and PageLoadMore
+	Button string
+	// Selector of the listing elements, as for ListingsJS
+	Listings string
+	// Selector waited for before a page is read, Listings by default
+	WaitFor string
+	// Listings on a full page, when known. A page with fewer is the last.
+	PageSize int
+	// Pages read at most, or scrolls for PageScroll. The max_pages of the
+	// source overrides it, 10 by default.
+	MaxPages int
+	// Wait after a click or scroll before the page is read. The page_wait of
+	// the source overrides it, 1s by default.
+	Wait time.Duration
+}
+
+var errLastPage = errors.New("no next page")
+
+// Paginate collects the listings of every page, in page order and without
+// listings that were already on an earlier page.
+func (s *Scraper) Paginate(b *browser.Browser, p Pagination) ([]db.Listing, error) {
+	switch p.Strategy {
+	case PageURL, PageNext, PageScroll, PageLoadMore:
+	default:
+		return nil, fmt.Errorf("unknown pagination strategy %q", p.Strategy)
+	}
+
+	maxPages := s.pages(p)
+	seen := make(map[string]bool)
+	listings := make([]db.Listing, 0)
+
+	for page := 1; page <= maxPages; page++ {
+		s.Logger.Info(fmt.Sprintf("Visit page %d", page))
+		err := s.openPage(b, p, page)
+		if page > 1 && errors.Is(err, errLastPage) {
+			s.Logger.Debug("Last page reached", "page", page-1)
+			break
+		}
+		if err != nil {
+			s.Logger.Error(fmt.Sprintf("Error opening page %d", page), "error", err)
+			s.HasError = true
+			return listings, err
+		}
+
+		var results []ListingResult
+		if err := b.RunInTab(s.TabCtx, chromedp.Evaluate(ListingsJS(p.Listings), &results)); err != nil {
+			s.Logger.Error(fmt.Sprintf("Error reading page %d", page), "error", err)
+			s.HasError = true
+			return listings, err
+		}
+
+		found := CleanListings(results)
+		added := 0
+		for _, listing := range found {
+			key := address.SourceKey(listing.Address)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			listings = append(listings, listing)
+			added++
+		}
+
+		if len(found) == 0 {
+			s.Logger.Debug("Stopping at an empty page", "page", page)
+			break
+		}
+		if added == 0 {
+			s.Logger.Debug("Stopping at a page without new listings", "page", page)
+			break
+		}
+		if p.PageSize > 0 && len(found) < p.PageSize {
+			s.Logger.Debug("Stopping at a page that isn't full", "page", page, "listings", len(found))
+			break
+		}
+		if page == maxPages {
+			s.Logger.Info("Reached the maximum number of pages", "max", maxPages)
+		}
+	}
+	return listings, nil
+}
+
+// openPage shows the page of results in the tab, errLastPage when there is
+// no such page.
+func (s *Scraper) openPage(b *browser.Browser, p Pagination, page int) error {
+	url := cmp.Or(p.URL, s.Url)
+	wait := cmp.Or(s.pageWait, p.Wait, defaultPageWait)
+
+	var actions []chromedp.Action
+	switch {
+	case p.Strategy == PageURL:
+		actions = append(actions, chromedp.Navigate(strings.ReplaceAll(url, "{page}", strconv.Itoa(page))), browser.DismissConsent(s.consent, nil))
+	case page == 1:
+		actions = append(actions, chromedp.Navigate(url), browser.DismissConsent(s.consent, nil))
+	case p.Strategy == PageNext || p.Strategy == PageLoadMore:
+		var clickable bool
+		err := b.RunInTab(s.TabCtx, chromedp.Evaluate(clickableJS(p.Button), &clickable))
+		if err != nil {
+			return err
+		}
+		if !clickable {
+			return errLastPage
+		}
+		// A banner that showed up late can be in front of the button
+		actions = append(actions, browser.DismissConsent(s.consent, nil), chromedp.Click(p.Button, chromedp.ByQuery), chromedp.Sleep(wait))
+	default:
+		// PageScroll, everything was loaded by scrolling the first page
+		return errLastPage
+	}
+	if err := b.RunInTab(s.TabCtx, actions...); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(s.TabCtx, pageTimeout)
+	defer cancel()
+	err := b.RunInTab(ctx, chromedp.WaitVisible(cmp.Or(p.WaitFor, p.Listings), chromedp.ByQuery))
+	if page > 1 && errors.Is(err, context.DeadlineExceeded) {
+		return errLastPage
+	}
+	if err != nil || p.Strategy != PageScroll {
+		return err
+	}
+
+	var items int
+	err = b.RunInTab(s.TabCtx, browser.ScrollUntilStable(p.Listings, browser.ScrollOptions{
+		Wait:       wait,
+		MaxScrolls: s.pages(p),
+	}, &items))
+	s.Logger.Debug("Scrolled through the results", "listings", items)
+	return err
+}
+
+// pages is the maximum number of pages to read, or for PageScroll of scrolls.
+func (s *Scraper) pages(p Pagination) int {
+	return cmp.Or(s.maxPages, p.MaxPages, defaultMaxPages)
+}
+
+// clickableJS returns a script that reports whether the button matching
+// selector is there and enabled.
+func clickableJS(selector string) string {
+	return fmt.Sprintf(`
+		(() => {
+			const button = document.querySelector(%q);
+			return !!button && !button.disabled && button.getAttribute('aria-disabled') !== 'true'
+				&& !button.classList.contains('disabled') && button.offsetParent !== null;
+		})()
+	`, selector)
+}
@@ -0,0 +1,57 @@
+package scraper
+
+import (
+	"context"
+
+	"huurwoning/db"
+)
+
+// downloadPhotos stores the first photos of new listings, so they are kept
+// after the listing is taken down. Photos that fail are left out.
+func (s *Scraper) downloadPhotos(listings []*db.Listing) {
+	if !s.photos.Download {
+		return
+	}
+
+	for _, listing := range listings {
+		if listing.Details == nil {
+			continue
+		}
+		for i := range listing.Details.Photos {
+			if i == s.photos.MaxPerListing {
+				break
+			}
+			photo := &listing.Details.Photos[i]
+			file, err := s.downloader.Download(context.Background(), photo.URL)
+			if err != nil {
+				s.Logger.Warn("Failed to download photo", "address", listing.Address, "url", photo.URL, "error", err)
+				continue
+			}
+			photo.File = file
+		}
+	}
+}
+
+// photoFiles returns the files of at most max downloaded photos of the alerts,
+// taking one photo of every listing before the next of any.
+func (s *Scraper) photoFiles(alerts []alert, max int) []string {
+	var files []string
+	for round := 0; len(files) < max; round++ {
+		added := false
+		for _, a := range alerts {
+			if a.listing.Details == nil || round >= len(a.listing.Details.Photos) || len(files) == max {
+				continue
+			}
+			added = true
+			if name := a.listing.Details.Photos[round].File; name != "" {
+				if path, err := s.photoStore.Path(name); err == nil {
+					files = append(files, path)
+				}
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return files
+}
@@ -1,53 +1,88 @@
 package scraper
 
 import (
+	"cmp"
 	"context"
 	"fmt"
-	"strings"
+	"slices"
+	"time"
 
+	"huurwoning/address"
+	"huurwoning/archive"
 	"huurwoning/browser"
+	"huurwoning/config"
 	"huurwoning/db"
+	"huurwoning/geo"
 	"huurwoning/logger"
+	"huurwoning/metrics"
+	"huurwoning/photos"
 	"huurwoning/reporting"
+	"huurwoning/scoring"
 
 	"github.com/chromedp/chromedp"
 )
 
 type GetResultsFactory func() GetResults
-type GetResults func(s *Scraper, b *browser.Browser) ([]string, error)
+type GetResults func(s *Scraper, b *browser.Browser) ([]db.Listing, error)
 
 type Scraper struct {
-	name        string
-	Url         string
-	username    string
-	password    string
-	HasError    bool
-	Logger      *logger.Logger
-	GetResults  GetResults
-	isDebugging bool
+	name         string
+	Url          string
+	username     string
+	password     string
+	HasError     bool
+	Logger       *logger.Logger
+	GetResults   GetResults
+	isDebugging  bool
+	dryRun       bool
+	filters      config.FilterConfig
+	maxPages     int
+	pageWait     time.Duration
+	details      config.DetailsConfig
+	consent      config.ConsentConfig
+	photos       config.PhotosConfig
+	photoStore   *photos.Store
+	downloader   *photos.Downloader
+	archive      config.ArchiveConfig
+	archiveStore *archive.Store
+	evaluator    *scoring.Evaluator
+	// Alerts of the last check, their scores are stored with the results
+	alerts      []alert
+	reporter    *reporting.Reporter
+	snapshotDir string
 	TabCtx      context.Context
 	tabCancel   context.CancelFunc
 	browser     *browser.Browser
 	db          *db.Database
+	runID       int64
 }
 
-func (s *Scraper) UpdatePrevResults(newResults []string) {
+func (s *Scraper) UpdatePrevResults(newResults []db.Listing) {
 	// unable to get all the new results, so we'll just return
-	if s.HasError || len(newResults) == 0 {
+	if s.HasError || s.dryRun || len(newResults) == 0 {
 		return
 	}
 
-	for _, k := range newResults {
-		err := s.db.UpsertProperty(k, s.name)
+	for _, listing := range newResults {
+		err := s.db.UpsertProperty(listing, s.name)
 		if err != nil {
 			s.Logger.Error("Failed to upsert property", "error", err)
 			s.HasError = true
 			return
 		}
 	}
+
+	for _, a := range s.alerts {
+		if !a.Scored() {
+			continue
+		}
+		if err := s.db.SetScore(s.name, a.listing.Address, a.Score, a.String()); err != nil {
+			s.Logger.Error("Failed to store score", "error", err)
+		}
+	}
 }
 
-func (s *Scraper) CheckForNewResults(foundResults []string) {
+func (s *Scraper) CheckForNewResults(foundResults []db.Listing) {
 	prevProperties, err := s.db.GetActiveProperties(s.name)
 	if err != nil {
 		s.Logger.Error("Failed to get active properties", "error", err)
@@ -55,37 +90,125 @@ func (s *Scraper) CheckForNewResults(foundResults []string) {
 		return
 	}
 
+	// Addresses are compared by key, so a listing written differently isn't new
 	prevResults := make(map[string]struct{})
 	for _, p := range prevProperties {
-		prevResults[p.Address] = struct{}{}
+		prevResults[address.SourceKey(p.Address)] = struct{}{}
 	}
 
 	// Compare current results with previous results and log new results
-	newResults := make([]string, 0)
-	for _, text := range foundResults {
-		if _, found := prevResults[text]; !found {
-			newResults = append(newResults, text)
+	fresh := make([]*db.Listing, 0)
+	for i := range foundResults {
+		key := address.SourceKey(foundResults[i].Address)
+		if _, found := prevResults[key]; !found {
+			fresh = append(fresh, &foundResults[i])
+			prevResults[key] = struct{}{}
+		}
+	}
+
+	// Details are added to the found results, so they are stored with them
+	s.enrich(fresh)
+	if !s.dryRun {
+		s.downloadPhotos(fresh)
+	}
+	newResults := make([]db.Listing, len(fresh))
+	for i, listing := range fresh {
+		newResults[i] = *listing
+	}
+
+	// Only alert on results that pass the filters, match a profile and
+	// weren't already alerted from another source, all results are stored
+	alerts := s.filterResults(s.skipDuplicates(newResults))
+	s.alerts = alerts
+
+	if s.dryRun {
+		s.logDryRun(foundResults, alerts)
+		return
+	}
+
+	metrics.ListingsFound.WithLabelValues(s.name).Set(float64(len(foundResults)))
+	metrics.NewListings.WithLabelValues(s.name).Add(float64(len(newResults)))
+	if s.runID != 0 {
+		if err := s.db.SetRunCounts(s.runID, len(foundResults), len(newResults)); err != nil {
+			s.Logger.Error("Failed to update scrape run", "error", err)
 		}
 	}
 
-	switch len(newResults) {
-	case 0:
+	if len(alerts) == 0 {
 		s.Logger.Info("No new results found.")
-	case 1:
-		s.Logger.Warn(fmt.Sprintf("New result found %s", newResults[0]))
-		if !s.isDebugging {
-			reporting.SendAlert(newResults[0], fmt.Sprintf("%s: ", s.name), s.Logger)
+	} else if !s.isDebugging {
+		s.sendAlerts(alerts)
+	}
+
+	// Partial results would mark the listings we didn't get to as gone
+	if s.HasError {
+		return
+	}
+	addresses := make([]string, len(foundResults))
+	for i, listing := range foundResults {
+		addresses[i] = listing.Address
+	}
+	if err := s.db.MarkInactive(s.name, addresses); err != nil {
+		s.Logger.Error("Failed to mark properties inactive", "error", err)
+	}
+}
+
+func (s *Scraper) filterResults(results []db.Listing) []alert {
+	alerts := make([]alert, 0, len(results))
+	for _, result := range results {
+		if !s.filters.Match(result.Address) {
+			s.Logger.Info("New result filtered out", "address", result.Address)
+			continue
+		}
+
+		// New listings aren't stored yet, so they are geocoded here
+		property := result.Property(s.name)
+		if err := geo.LocateProperty(s.db, &property); err != nil {
+			s.Logger.Error("Failed to geocode new result", "address", result.Address, "error", err)
+		}
+
+		evaluation, ok, err := s.evaluator.Evaluate(property)
+		if err != nil {
+			s.Logger.Error("Failed to estimate commute", "address", result.Address, "error", err)
 		}
-	default:
-		s.Logger.Warn(fmt.Sprintf("%d new results found.", len(newResults)))
-		if !s.isDebugging {
-			// Send one alert with all results
-			allResults := strings.Join(newResults, "\n")
-			reporting.SendAlertForMultipleResults(allResults, fmt.Sprintf("%s: Multiple Results\n", s.name), s.Logger)
+		if !ok {
+			s.Logger.Info("New result matches no profile or is ignored by a rule", "address", result.Address)
+			continue
 		}
+		alerts = append(alerts, alert{listing: result, Evaluation: evaluation})
 	}
 
-	s.db.MarkInactive(s.name, newResults)
+	// Highest priority first, then best scored, unscored last
+	slices.SortStableFunc(alerts, func(a, b alert) int {
+		if c := scoring.ComparePriority(a.Priority, b.Priority); c != 0 {
+			return c
+		}
+		if a.Scored() != b.Scored() {
+			if a.Scored() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return alerts
+}
+
+// skipDuplicates leaves out listings of homes that another source already lists.
+func (s *Scraper) skipDuplicates(results []db.Listing) []db.Listing {
+	unique := make([]db.Listing, 0, len(results))
+	for _, result := range results {
+		other, err := s.db.ListedElsewhere(result, s.name)
+		if err != nil {
+			s.Logger.Error("Failed to look for duplicates", "error", err)
+		}
+		if other != nil {
+			s.Logger.Info("New result already listed by another source", "address", result.Address, "source", other.Source, "listing", other.Address)
+			continue
+		}
+		unique = append(unique, result)
+	}
+	return unique
 }
 
 func (s *Scraper) createTab() error {
@@ -94,10 +217,23 @@ func (s *Scraper) createTab() error {
 	if err != nil {
 		return fmt.Errorf("failed to create tab: %v", err)
 	}
+
+	// Before the first page, so cookie banners are handled on every page
+	if err := s.browser.RunInTab(s.TabCtx, browser.SetupConsent(s.consent, s.Url)); err != nil {
+		s.tabCancel()
+		s.TabCtx, s.tabCancel = nil, nil
+		s.browser.DecreaseTabCount()
+		return fmt.Errorf("failed to set up cookie consent: %v", err)
+	}
 	return nil
 }
 
 func (s *Scraper) Close() {
+	// Keep what the page looked like when something went wrong
+	if s.HasError && !s.dryRun && s.TabCtx != nil {
+		s.saveSnapshot()
+	}
+
 	if s.tabCancel != nil {
 		s.tabCancel()
 		s.TabCtx = nil
@@ -123,6 +259,14 @@ func (s *Scraper) ensureBrowserAlive() error {
 }
 
 func (s *Scraper) LoginIfNeeded(b *browser.Browser) error {
+	err := s.login(b)
+	if err != nil {
+		s.HasError = true
+	}
+	return err
+}
+
+func (s *Scraper) login(b *browser.Browser) error {
 	err := s.ensureBrowserAlive()
 	if err != nil {
 		return err
@@ -184,21 +328,48 @@ func (s *Scraper) LoginIfNeeded(b *browser.Browser) error {
 	return nil
 }
 
-func NewScraper(b *browser.Browser, name, url string, password string, hasError bool, logger *logger.Logger, getResultsFactory GetResultsFactory, isDebugging bool, db *db.Database) (*Scraper, error) {
+// NewScraper creates the scraper of source name in a new tab. runID is the
+// scrape run it records its counts and snapshot on, 0 records nothing.
+func NewScraper(b *browser.Browser, cfg *config.Config, name string, logger *logger.Logger, getResultsFactory GetResultsFactory, isDebugging bool, db *db.Database, runID int64) (*Scraper, error) {
+	source := cfg.Source(name)
+	if source == nil {
+		return nil, fmt.Errorf("source %s is not configured", name)
+	}
+
 	s := &Scraper{
-		name:        name,
-		Url:         url,
-		username:    "martijnspitter@gmail.com",
-		password:    password,
-		HasError:    hasError,
-		Logger:      logger,
-		GetResults:  getResultsFactory(),
-		isDebugging: isDebugging,
-		browser:     b,
-		db:          db,
-	}
-
-	err := s.createTab()
+		name:         source.Name,
+		Url:          source.URL,
+		username:     source.Username,
+		password:     source.Password.Value(),
+		Logger:       logger,
+		GetResults:   getResultsFactory(),
+		isDebugging:  isDebugging,
+		dryRun:       cfg.SourceDryRun(*source),
+		filters:      cfg.SourceFilters(*source),
+		maxPages:     source.MaxPages,
+		pageWait:     source.PageWait,
+		details:      source.Details,
+		consent:      source.Consent,
+		photos:       cfg.Photos,
+		photoStore:   photos.NewStore(cfg.PhotoDir()),
+		archive:      cfg.Archive,
+		archiveStore: archive.NewStore(cfg.ArchiveDir()),
+		reporter:     reporting.New(cfg.Notifiers),
+		snapshotDir:  cfg.SnapshotDir(),
+		browser:      b,
+		db:           db,
+		runID:        runID,
+	}
+
+	s.downloader = photos.NewDownloader(s.photoStore, int64(cfg.Photos.MaxSizeKB)*1024, cfg.Browser.UserAgent)
+
+	evaluator, err := scoring.NewEvaluator(cfg)
+	if err != nil {
+		logger.Warn("Estimating commute times in a straight line", "error", err)
+	}
+	s.evaluator = evaluator
+
+	err = s.createTab()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tab: %v", err)
 	}
@@ -0,0 +1,75 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	snapshotTimeout = 10 * time.Second
+	// Snapshots kept per source, older ones are removed
+	snapshotsPerSource = 20
+)
+
+// saveSnapshot stores a screenshot and the HTML of the current page as
+// <source>-<time>.png and .html, and links them to the running scrape run.
+func (s *Scraper) saveSnapshot() {
+	if err := os.MkdirAll(s.snapshotDir, 0755); err != nil {
+		s.Logger.Warn("Failed to create snapshot directory", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.TabCtx, snapshotTimeout)
+	defer cancel()
+
+	var screenshot []byte
+	var html string
+	err := chromedp.Run(ctx,
+		chromedp.CaptureScreenshot(&screenshot),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if err != nil {
+		s.Logger.Warn("Failed to take snapshot", "error", err)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%s", s.name, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(filepath.Join(s.snapshotDir, name+".png"), screenshot, 0644); err != nil {
+		s.Logger.Warn("Failed to save snapshot", "error", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(s.snapshotDir, name+".html"), []byte(html), 0644); err != nil {
+		s.Logger.Warn("Failed to save snapshot", "error", err)
+		return
+	}
+
+	if s.runID != 0 {
+		if err := s.db.SetRunSnapshot(s.runID, name); err != nil {
+			s.Logger.Warn("Failed to link snapshot to scrape run", "error", err)
+		}
+	}
+	s.Logger.Info("Saved snapshot", "snapshot", name)
+
+	s.pruneSnapshots()
+}
+
+func (s *Scraper) pruneSnapshots() {
+	screenshots, err := filepath.Glob(filepath.Join(s.snapshotDir, s.name+"-*.png"))
+	if err != nil || len(screenshots) <= snapshotsPerSource {
+		return
+	}
+
+	// Names sort by time
+	sort.Strings(screenshots)
+	for _, screenshot := range screenshots[:len(screenshots)-snapshotsPerSource] {
+		os.Remove(screenshot)
+		os.Remove(strings.TrimSuffix(screenshot, ".png") + ".html")
+	}
+}
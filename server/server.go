@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"huurwoning/logger"
+)
+
+// Server is the HTTP server for the endpoints next to the scraper, like
+// /metrics.
+type Server struct {
+	http   *http.Server
+	mux    *http.ServeMux
+	logger *logger.Logger
+}
+
+func New(addr string, globalLogger *logger.GlobalLogger) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		http: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		mux:    mux,
+		logger: globalLogger.Logger("SERVER"),
+	}
+}
+
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start serves in the background. The scraper keeps running when the server fails.
+func (s *Server) Start() {
+	go func() {
+		s.logger.Info("Listening", "addr", s.http.Addr)
+		err := s.http.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Server stopped", "error", err)
+		}
+	}()
+}
+
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.http.Shutdown(ctx)
+}
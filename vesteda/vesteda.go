@@ -7,22 +7,16 @@ import (
 	"huurwoning/db"
 	"huurwoning/logger"
 	"huurwoning/scraper"
-	"strings"
 
 	"github.com/chromedp/chromedp"
 )
 
-func Vesteda(b *browser.Browser, globalLogger *logger.GlobalLogger, url string, db *db.Database) error {
+func Vesteda(b *browser.Browser, globalLogger *logger.GlobalLogger, config *config.Config, db *db.Database, runID int64) ([]db.Listing, error) {
 	logger := globalLogger.Logger("VESTEDA")
 
-	config, err := config.LoadConfig()
+	scraper, err := scraper.NewScraper(b, config, "VESTEDA", logger, GetResultsFactory, config.Debug, db, runID)
 	if err != nil {
-		return fmt.Errorf("Failed to load config: %v", err)
-	}
-
-	scraper, err := scraper.NewScraper(b, "VESTEDA", url, config.VESTEDA_PW, false, logger, GetResultsFactory, config.DEBUG_MODE, db)
-	if err != nil {
-		return fmt.Errorf("failed to create scraper: %v", err)
+		return nil, fmt.Errorf("failed to create scraper: %v", err)
 	}
 	defer scraper.Close()
 
@@ -31,53 +25,39 @@ func Vesteda(b *browser.Browser, globalLogger *logger.GlobalLogger, url string,
 	// Login if needed
 	err = scraper.LoginIfNeeded(b)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	newResults, err := scraper.GetResults(scraper, b)
 	if err != nil {
 		scraper.CheckForNewResults(newResults)
 		scraper.UpdatePrevResults(newResults)
-		return err
+		return newResults, err
 	}
 
 	scraper.CheckForNewResults(newResults)
 	scraper.UpdatePrevResults(newResults)
-	return nil
+	return newResults, nil
 }
 
 func GetResultsFactory() scraper.GetResults {
 	return GetResults
 }
 
-func GetResults(scraper *scraper.Scraper, b *browser.Browser) ([]string, error) {
-	var results []string
+func GetResults(s *scraper.Scraper, b *browser.Browser) ([]db.Listing, error) {
+	var results []scraper.ListingResult
 
-	err := b.RunInTab(scraper.TabCtx,
+	err := b.RunInTab(s.TabCtx,
 		chromedp.Navigate("https://hurenbij.vesteda.com/zoekopdracht/"),
 		chromedp.WaitVisible("a.stretched-link", chromedp.ByQuery),
-		chromedp.Evaluate(`
-			Array.from(document.querySelectorAll('a.stretched-link')).map(el => el.textContent.trim())
-		`, &results),
+		chromedp.Evaluate(scraper.ListingsJS("a.stretched-link"), &results),
 	)
 
 	if err != nil {
-		scraper.Logger.Error("Error getting results", err)
-		scraper.HasError = true
-		return results, fmt.Errorf("Error getting results: %v", err)
-	}
-
-	// Create a map to store the current results
-	currentResults := make([]string, 0, len(results))
-
-	// Iterate over the elements and store their text in the current results map
-	for _, result := range results {
-		if result == "" {
-			continue
-		}
-		trimmed := strings.TrimSpace(result)
-		currentResults = append(currentResults, trimmed)
+		s.Logger.Error("Error getting results", "error", err)
+		s.HasError = true
+		return scraper.CleanListings(results), fmt.Errorf("Error getting results: %v", err)
 	}
 
-	return currentResults, nil
+	return scraper.CleanListings(results), nil
 }